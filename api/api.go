@@ -0,0 +1,451 @@
+// a JSON over HTTP interface to a node, so scripts and dashboards can
+// use a running node instead of starting the CLI (which has to open the
+// database again) for every single thing
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"blockchain/chain"
+	"blockchain/tx"
+	"blockchain/utxo"
+	"blockchain/wallet"
+)
+
+// the most headers /getheaders gives back at once
+const maxHeaders = 2000
+
+// the header an encrypted wallets file's passphrase comes in. Each request
+// that opens the wallets needs it, the server doesn't keep one around
+const PassphraseHeader = "X-Wallet-Passphrase"
+
+// Server answers the API requests using one open blockchain.
+// Every endpoint is named after the CLI command that does the same thing
+type Server struct {
+	bc *chain.Blockchain
+	// the wallets file the wallet endpoints use
+	walletPath string
+	// the endpoints that change things (send, createwallet) only answer
+	// requests with "Authorization: Bearer TOKEN"
+	token string
+	mux   *http.ServeMux
+	// requests are handled in their own goroutines. Reading at the same
+	// time is fine, but send and createwallet change things (the chain,
+	// the mempool, the wallets file) so those get the lock to themselves
+	lock sync.RWMutex
+}
+
+// what a handler hands back, either something to send as JSON or an error
+type handlerFunc func(r *http.Request) (interface{}, error)
+
+// an error that is the client's fault, like a missing parameter
+type requestError struct {
+	msg string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{fmt.Sprintf(format, args...)}
+}
+
+func NewServer(bc *chain.Blockchain, walletPath, token string) *Server {
+	s := &Server{
+		bc:         bc,
+		walletPath: walletPath,
+		token:      token,
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/getbalance", s.handle(http.MethodGet, false, s.getBalance))
+	s.mux.HandleFunc("/send", s.handle(http.MethodPost, true, s.send))
+	s.mux.HandleFunc("/createwallet", s.handle(http.MethodPost, true, s.createWallet))
+	s.mux.HandleFunc("/listaddresses", s.handle(http.MethodGet, false, s.listAddresses))
+	s.mux.HandleFunc("/getblock", s.handle(http.MethodGet, false, s.getBlock))
+	s.mux.HandleFunc("/getheaders", s.handle(http.MethodGet, false, s.getHeaders))
+	s.mux.HandleFunc("/gettransaction", s.handle(http.MethodGet, false, s.getTransaction))
+	s.mux.HandleFunc("/tip", s.handle(http.MethodGet, false, s.tip))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// serves the API for bc on addr (like "127.0.0.1:8080") until the listener fails
+func ListenAndServe(addr string, bc *chain.Blockchain, walletPath, token string) error {
+	return http.ListenAndServe(addr, NewServer(bc, walletPath, token))
+}
+
+// wraps a handler so it only answers to method, holds the lock while it
+// runs, and has its result (or error) written back as JSON. Handlers that
+// write also need the token
+func (s *Server) handle(method string, writes bool, h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"method_not_allowed", "use " + method})
+			return
+		}
+		if writes && !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "send the API token as Authorization: Bearer TOKEN"})
+			return
+		}
+
+		if writes {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+		} else {
+			s.lock.RLock()
+			defer s.lock.RUnlock()
+		}
+
+		result, err := h(r)
+		if err != nil {
+			status, code := errorStatus(err)
+			writeJSON(w, status, errorResponse{code, err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// whether the request has the server's token. Compared in constant
+// time so how long a wrong guess takes says nothing about the token
+func (s *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") || s.token == "" {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// the wallets file, with the passphrase from the request if it's encrypted
+func (s *Server) wallets(r *http.Request) (*wallet.Wallets, error) {
+	return wallet.NewWallets(s.walletPath, func() ([]byte, error) {
+		pass := r.Header.Get(PassphraseHeader)
+		if pass == "" {
+			return nil, fmt.Errorf("%w: the wallets are encrypted, send the passphrase in %s", wallet.ErrWrongPassphrase, PassphraseHeader)
+		}
+		return []byte(pass), nil
+	})
+}
+
+// picks the HTTP status and a short code for an error, the code
+// is so scripts don't have to look at the message to tell them apart
+func errorStatus(err error) (int, string) {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		return http.StatusBadRequest, "bad_request"
+	case errors.Is(err, wallet.ErrInvalidAddress):
+		return http.StatusBadRequest, "invalid_address"
+	case errors.Is(err, tx.ErrInsufficientFunds):
+		return http.StatusUnprocessableEntity, "insufficient_funds"
+	case errors.Is(err, tx.ErrInvalidSignature):
+		return http.StatusUnprocessableEntity, "invalid_signature"
+	case errors.Is(err, tx.ErrLocked):
+		return http.StatusUnprocessableEntity, "locked"
+	case errors.Is(err, wallet.ErrWrongPassphrase):
+		return http.StatusForbidden, "wrong_passphrase"
+	case errors.Is(err, wallet.ErrWalletNotFound):
+		return http.StatusNotFound, "wallet_not_found"
+	case errors.Is(err, tx.ErrUnknownTransaction):
+		return http.StatusNotFound, "unknown_transaction"
+	case errors.Is(err, chain.ErrBlockNotFound):
+		return http.StatusNotFound, "block_not_found"
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// GET /getbalance?address=ADDRESS
+func (s *Server) getBalance(r *http.Request) (interface{}, error) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		return nil, badRequest("address is required")
+	}
+	return s.balance(address)
+}
+
+// adds up the unspent outputs locked with address
+func (s *Server) balance(address string) (balanceResponse, error) {
+	pubKeyHash, err := wallet.GetPubkeyhashFromAddr(address)
+	if err != nil {
+		return balanceResponse{}, err
+	}
+
+	utxoset := utxo.UTXOSet{
+		Blockchain: s.bc,
+	}
+	outputs, err := utxoset.FindUTXO(pubKeyHash)
+	if err != nil {
+		return balanceResponse{}, err
+	}
+
+	balance := 0
+	for _, output := range outputs {
+		balance += output.Value
+	}
+	return balanceResponse{address, balance}, nil
+}
+
+// POST /send with a JSON body like the flags of the send command:
+// {"from": ..., "to": ..., "amount": 5, "fee": 1, "mine": true}.
+// Just like the CLI, mine defaults to true and the sender gets the
+// block reward. With "mine": false the transaction goes in the mempool
+func (s *Server) send(r *http.Request) (interface{}, error) {
+	var req sendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, badRequest("body is not valid JSON: %s", err)
+	}
+	if req.From == "" || req.To == "" {
+		return nil, badRequest("from and to are required")
+	}
+	if req.Amount <= 0 || req.Fee < 0 {
+		return nil, badRequest("amount has to be positive and fee can't be negative")
+	}
+	if req.LockTime < 0 || req.RelativeLock < 0 {
+		return nil, badRequest("lock times can't be negative")
+	}
+	mineNow := req.Mine == nil || *req.Mine
+
+	if !wallet.ValidateAddress(req.From) {
+		return nil, fmt.Errorf("%w: sender %q", wallet.ErrInvalidAddress, req.From)
+	}
+	if !wallet.ValidateAddress(req.To) {
+		return nil, fmt.Errorf("%w: recipient %q", wallet.ErrInvalidAddress, req.To)
+	}
+
+	wallets, err := s.wallets(r)
+	if err != nil {
+		return nil, err
+	}
+	utxoset := utxo.UTXOSet{
+		Blockchain: s.bc,
+	}
+	locks := tx.Locks{LockTime: req.LockTime, RelativeLock: req.RelativeLock}
+	transaction, err := tx.NewTransactionFromWallets(wallets, req.From, req.To, req.Amount, req.Fee, locks, &utxoset, s.bc)
+	if err != nil {
+		return nil, err
+	}
+	resp := sendResponse{
+		TxID: hex.EncodeToString(transaction.ID),
+		Fee:  req.Fee,
+	}
+
+	// locked transactions wait in the mempool, like with the CLI
+	lockErr := s.bc.CheckLocks(transaction)
+	if lockErr != nil && !errors.Is(lockErr, tx.ErrLocked) {
+		return nil, lockErr
+	}
+	resp.Locked = lockErr != nil
+
+	if !mineNow || resp.Locked {
+		mempool := utxo.Mempool{
+			Blockchain: s.bc,
+		}
+		if err := mempool.Add(transaction); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	block, err := utxo.MineBlock(s.bc, []*tx.Transaction{transaction}, req.From)
+	if err != nil {
+		return nil, err
+	}
+	resp.Block = hex.EncodeToString(block.Hash)
+	return resp, nil
+}
+
+// POST /createwallet, gives back the next address. The mnemonic is the
+// backup of every key, so it never goes over the API. Making the first
+// address (which makes the mnemonic) has to be done with the CLI, where
+// it gets shown to whoever is at the terminal
+func (s *Server) createWallet(r *http.Request) (interface{}, error) {
+	wallets, err := s.wallets(r)
+	if err != nil {
+		return nil, err
+	}
+	if wallets.Mnemonic == "" {
+		return nil, badRequest("the wallets don't have a mnemonic yet, run createwallet on the command line first")
+	}
+	address, err := wallets.CreateWallet()
+	if err != nil {
+		return nil, err
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		return nil, err
+	}
+	return walletResponse{Address: address}, nil
+}
+
+// GET /listaddresses, every address in the wallets file (multisigs
+// included) with its balance
+func (s *Server) listAddresses(r *http.Request) (interface{}, error) {
+	wallets, err := s.wallets(r)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []balanceResponse{}
+	for address := range wallets.Wallets {
+		balance, err := s.balance(address)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, balance)
+	}
+	for address := range wallets.Multisig {
+		balance, err := s.balance(address)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, balance)
+	}
+	// maps don't keep an order, sort so the output doesn't jump around
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Address < addresses[j].Address
+	})
+	return addresses, nil
+}
+
+// GET /getblock?hash=HEX or /getblock?height=N
+func (s *Server) getBlock(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	var block *chain.Block
+	var err error
+	switch {
+	case query.Get("hash") != "":
+		hash, decodeErr := hex.DecodeString(query.Get("hash"))
+		if decodeErr != nil {
+			return nil, badRequest("hash is not hex: %s", decodeErr)
+		}
+		block, err = s.bc.GetBlock(hash)
+	case query.Get("height") != "":
+		height, convErr := strconv.Atoi(query.Get("height"))
+		if convErr != nil || height < 0 {
+			return nil, badRequest("height has to be a number, 0 or more")
+		}
+		block, err = s.bc.GetBlockByHeight(height)
+	default:
+		return nil, badRequest("hash or height is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlockJSON(block, s.bc.ValidateProofOfWork(block)), nil
+}
+
+// GET /getheaders?from=HEX&count=N, the headers of the main chain after
+// the block with hash from (from the genesis block without it), oldest
+// first. Enough for a light client to follow the chain without blocks
+func (s *Server) getHeaders(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	from, err := hex.DecodeString(query.Get("from"))
+	if err != nil {
+		return nil, badRequest("from is not hex: %s", err)
+	}
+	count := maxHeaders
+	if query.Get("count") != "" {
+		count, err = strconv.Atoi(query.Get("count"))
+		if err != nil || count < 1 || count > maxHeaders {
+			return nil, badRequest("count has to be a number from 1 to %d", maxHeaders)
+		}
+	}
+
+	var locator [][]byte
+	if len(from) != 0 {
+		locator = [][]byte{from}
+	}
+	headers, err := s.bc.HeadersAfter(locator, count)
+	if err != nil {
+		return nil, err
+	}
+	resp := []headerJSON{}
+	for _, header := range headers {
+		resp = append(resp, newHeaderJSON(header))
+	}
+	return resp, nil
+}
+
+// GET /gettransaction?txid=HEX. Looks on the chain first and then in the
+// mempool, a transaction that is still waiting has no block yet
+func (s *Server) getTransaction(r *http.Request) (interface{}, error) {
+	txid, err := hex.DecodeString(r.URL.Query().Get("txid"))
+	if err != nil || len(txid) == 0 {
+		return nil, badRequest("txid is required and has to be hex")
+	}
+
+	transaction, block, err := s.bc.FindTransactionBlock(txid)
+	if err == nil {
+		height := block.Height
+		return transactionResponse{
+			Transaction: newTransactionJSON(&transaction),
+			Confirmed:   true,
+			Block:       hex.EncodeToString(block.Hash),
+			Height:      &height,
+		}, nil
+	}
+	if !errors.Is(err, tx.ErrUnknownTransaction) {
+		return nil, err
+	}
+
+	mempool := utxo.Mempool{
+		Blockchain: s.bc,
+	}
+	pending, ok, mempoolErr := mempool.Get(txid)
+	if mempoolErr != nil {
+		return nil, mempoolErr
+	}
+	if !ok {
+		return nil, err
+	}
+	return transactionResponse{
+		Transaction: newTransactionJSON(&pending),
+		Confirmed:   false,
+	}, nil
+}
+
+// GET /tip, the latest block. A node that hasn't synced has height -1
+func (s *Server) tip(r *http.Request) (interface{}, error) {
+	resp := tipResponse{
+		Height: s.bc.GetBestHeight(),
+	}
+	if resp.Height < 0 {
+		return resp, nil
+	}
+
+	block, err := s.bc.GetBlock(s.bc.LatestHash)
+	if err != nil {
+		return nil, err
+	}
+	resp.Hash = hex.EncodeToString(block.Hash)
+	resp.Timestamp = block.Timestamp
+	resp.Bits = block.Bits
+	resp.NextSubsidy = chain.BlockSubsidy(block.Height + 1)
+	return resp, nil
+}
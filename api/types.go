@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/hex"
+
+	"blockchain/chain"
+	"blockchain/script"
+	"blockchain/tx"
+)
+
+// the shapes of the JSON the API sends and receives. Hashes and keys are
+// written as hex instead of the base64 encoding/json would use for []byte,
+// so they look the same as in the CLI output
+
+type errorResponse struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+type balanceResponse struct {
+	Address string `json:"address"`
+	Balance int    `json:"balance"`
+}
+
+type sendRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+	Fee    int    `json:"fee"`
+	// a pointer so we can tell false apart from not given (which means true)
+	Mine *bool `json:"mine"`
+	// see tx.Locks
+	LockTime     int64 `json:"lockTime"`
+	RelativeLock int   `json:"relativeLock"`
+}
+
+type sendResponse struct {
+	TxID string `json:"txid"`
+	Fee  int    `json:"fee"`
+	// hash of the block the transaction was mined in, empty if it's in the mempool
+	Block string `json:"block,omitempty"`
+	// the transaction's lock time hasn't passed, so it's in the mempool
+	Locked bool `json:"locked,omitempty"`
+}
+
+type walletResponse struct {
+	Address string `json:"address"`
+}
+
+type tipResponse struct {
+	Hash        string `json:"hash,omitempty"`
+	Height      int    `json:"height"`
+	Timestamp   int64  `json:"timestamp,omitempty"`
+	Bits        int    `json:"bits,omitempty"`
+	NextSubsidy int    `json:"nextSubsidy,omitempty"`
+}
+
+type transactionResponse struct {
+	Transaction transactionJSON `json:"transaction"`
+	// false while it's waiting in the mempool
+	Confirmed bool   `json:"confirmed"`
+	Block     string `json:"block,omitempty"`
+	Height    *int   `json:"height,omitempty"`
+}
+
+type blockJSON struct {
+	Hash         string            `json:"hash"`
+	Version      int               `json:"version"`
+	PrevHash     string            `json:"prevHash"`
+	MerkleRoot   string            `json:"merkleRoot"`
+	Height       int               `json:"height"`
+	Timestamp    int64             `json:"timestamp"`
+	Bits         int               `json:"bits"`
+	Nonce        int               `json:"nonce"`
+	ValidPoW     bool              `json:"validPoW"`
+	Transactions []transactionJSON `json:"transactions"`
+}
+
+type headerJSON struct {
+	Hash       string `json:"hash"`
+	Version    int    `json:"version"`
+	PrevHash   string `json:"prevHash"`
+	MerkleRoot string `json:"merkleRoot"`
+	Height     int    `json:"height"`
+	Timestamp  int64  `json:"timestamp"`
+	Bits       int    `json:"bits"`
+	Nonce      int    `json:"nonce"`
+}
+
+type transactionJSON struct {
+	ID       string       `json:"id"`
+	Coinbase bool         `json:"coinbase"`
+	LockTime int64        `json:"lockTime,omitempty"`
+	Inputs   []inputJSON  `json:"inputs"`
+	Outputs  []outputJSON `json:"outputs"`
+}
+
+type inputJSON struct {
+	TxID      string `json:"txid"`
+	Output    int    `json:"output"`
+	Signature string `json:"signature"`
+	PublicKey string `json:"publicKey"`
+}
+
+type outputJSON struct {
+	Value         int    `json:"value"`
+	PublicKeyHash string `json:"publicKeyHash"`
+	Address       string `json:"address"`
+	Multisig      bool   `json:"multisig,omitempty"`
+	// only outputs with a script of their own, written out like
+	// "DUP HASH160 <hex> EQUALVERIFY CHECKSIG"
+	Script       string `json:"script,omitempty"`
+	RelativeLock int    `json:"relativeLock,omitempty"`
+}
+
+func newBlockJSON(block *chain.Block, validPoW bool) blockJSON {
+	b := blockJSON{
+		Hash:         hex.EncodeToString(block.Hash),
+		Version:      block.Version,
+		PrevHash:     hex.EncodeToString(block.PrevBlockHash),
+		MerkleRoot:   hex.EncodeToString(block.MerkleRoot),
+		Height:       block.Height,
+		Timestamp:    block.Timestamp,
+		Bits:         block.Bits,
+		Nonce:        block.Nonce,
+		ValidPoW:     validPoW,
+		Transactions: []transactionJSON{},
+	}
+	for _, transaction := range block.Transactions {
+		b.Transactions = append(b.Transactions, newTransactionJSON(transaction))
+	}
+	return b
+}
+
+func newHeaderJSON(header *chain.BlockHeader) headerJSON {
+	return headerJSON{
+		Hash:       hex.EncodeToString(header.Hash()),
+		Version:    header.Version,
+		PrevHash:   hex.EncodeToString(header.PrevBlockHash),
+		MerkleRoot: hex.EncodeToString(header.MerkleRoot),
+		Height:     header.Height,
+		Timestamp:  header.Timestamp,
+		Bits:       header.Bits,
+		Nonce:      header.Nonce,
+	}
+}
+
+func newTransactionJSON(transaction *tx.Transaction) transactionJSON {
+	t := transactionJSON{
+		ID:       hex.EncodeToString(transaction.ID),
+		Coinbase: transaction.IsCoinbase(),
+		LockTime: transaction.LockTime,
+		Inputs:   []inputJSON{},
+		Outputs:  []outputJSON{},
+	}
+	// a coinbase input doesn't spend anything, so it's left out
+	if !t.Coinbase {
+		for _, vin := range transaction.Vin {
+			t.Inputs = append(t.Inputs, inputJSON{
+				TxID:      hex.EncodeToString(vin.Txid),
+				Output:    vin.OutputIdx,
+				Signature: hex.EncodeToString(vin.Signature),
+				PublicKey: hex.EncodeToString(vin.PublicKey),
+			})
+		}
+	}
+	for _, vout := range transaction.Vout {
+		out := outputJSON{
+			Value:         vout.Value,
+			PublicKeyHash: hex.EncodeToString(vout.PublicKeyHash),
+			Address:       vout.Address(),
+			Multisig:      vout.Multisig,
+			RelativeLock:  vout.RelativeLock,
+		}
+		if len(vout.Script) > 0 {
+			out.Script = script.Disassemble(vout.Script)
+		}
+		t.Outputs = append(t.Outputs, out)
+	}
+	return t
+}
@@ -0,0 +1,187 @@
+// the blockchain itself: blocks, how they're stored, and the
+// rules a block has to follow to go on the chain
+package chain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"time"
+
+	"blockchain/pow"
+	"blockchain/tx"
+)
+
+// In Bitcoin specification, Timestamp, PrevBlockHash, and Hash are
+// block headers, which form a separate data structure, and
+// transactions (Data in our case) is a separate data structure.
+// Header gives back the header part as a BlockHeader. The fields stay
+// in Block itself since that's how the blocks already stored decode
+type Block struct {
+	Timestamp     int64
+	Transactions  []*tx.Transaction
+	PrevBlockHash []byte
+	Hash          []byte
+	Nonce         int
+	// the difficulty, how many leading zero bits the hash needs
+	Bits int
+	// how many blocks come before this one, the genesis block is 0
+	Height int
+	// see BlockHeader. The blocks from the first version of the program
+	// are version 0 and have no Merkle root, see migrateOriginalChain
+	Version    int
+	MerkleRoot []byte
+}
+
+// a function to create a new block given some data that the block should store
+// and the previous block hash
+func NewBlock(transactions []*tx.Transaction, prevBlockHash []byte, height, bits int, timestamp int64) (*Block, error) {
+	ret := Block{
+		Timestamp:     timestamp,
+		Transactions:  transactions,
+		PrevBlockHash: prevBlockHash,
+		Height:        height,
+		Bits:          bits,
+		Version:       BlockVersion,
+	}
+	merkleRoot, err := ret.HashTransactions()
+	if err != nil {
+		return nil, err
+	}
+	ret.MerkleRoot = merkleRoot
+
+	// first ask proof of work to find the right nonce and hash
+	// for this block. Only the header gets hashed
+	header := ret.Header()
+	proof, err := pow.NewProofOfWork(&header)
+	if err != nil {
+		return nil, err
+	}
+	nonce, hash := proof.Run()
+	ret.Hash = hash[:]
+	ret.Nonce = nonce
+
+	return &ret, nil
+}
+
+// the first block on the chain
+func GenesisBlock(coinbase *tx.Transaction) (*Block, error) {
+	return NewBlock([]*tx.Transaction{coinbase}, []byte{}, 0, targetBits, time.Now().Unix())
+}
+
+// the block without its transactions
+func (b *Block) Header() BlockHeader {
+	header := BlockHeader{
+		Version:       b.Version,
+		PrevBlockHash: b.PrevBlockHash,
+		MerkleRoot:    b.MerkleRoot,
+		Timestamp:     b.Timestamp,
+		Bits:          b.Bits,
+		Nonce:         b.Nonce,
+		Height:        b.Height,
+	}
+	if b.Version == 0 {
+		header.OriginalHash = b.Hash
+	}
+	return header
+}
+
+// the hash of the block header with the nonce the block was mined with,
+// this should be exactly what is stored in the block's Hash field
+func (b *Block) HeaderHash() []byte {
+	header := b.Header()
+	return header.Hash()
+}
+
+// a function to serialize the Block struct to a []byte so we can
+// store it inside the DB. We use encoding/gob package to do the encoding
+// for us, its very efficient.
+// gob can only fail here if the Block struct itself is broken (a field
+// type gob can't handle), which is a bug and not something to recover from
+func (b *Block) Serialize() []byte {
+	var output bytes.Buffer
+	enc := gob.NewEncoder(&output)
+	err := enc.Encode(b)
+	if err != nil {
+		log.Panic("Encode err:", err)
+	}
+	return output.Bytes()
+}
+
+// opposite of Serialize, has to take a Block from the database (or
+// a peer) and put it back into our Block struct
+func DeserializeBlock(b []byte) (*Block, error) {
+	var block Block
+
+	// we need to make a new bytes.Reader here, since NewDecoder expects this
+	dec := gob.NewDecoder(bytes.NewReader(b))
+	err := dec.Decode(&block)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding block: %w", err)
+	}
+	// every block has at least its coinbase
+	if len(block.Transactions) == 0 {
+		return nil, fmt.Errorf("Block %x has no transactions", block.Hash)
+	}
+	return &block, nil
+}
+
+// Called by NewBlock, this assumes all transactions have been added to
+// the block and that each one has an ID via setID(), so we will now represent
+// all the transactions with a single hash. This is done via the Merkel Tree
+// which hashes up all the serialized forms of the transactions,
+// into a singular one in a tree-like structure
+// we then return the resulting hash.
+func (b *Block) HashTransactions() ([]byte, error) {
+	// create Merkel Tree for this block
+	mt, err := b.merkleTree()
+	if err != nil {
+		return nil, err
+	}
+	rootNodeData := mt.RootNode.Hash
+
+	return rootNodeData, nil
+}
+
+func (b *Block) merkleTree() (MerkleTree, error) {
+	var txHashes [][]byte
+
+	// add each transaction's ID and fields. We can't use Serialize here
+	// since gob's output isn't the same from one program run to the next
+	for _, transaction := range b.Transactions {
+		txHashes = append(txHashes, merkleLeafData(transaction))
+	}
+	return NewMerkleTree(txHashes)
+}
+
+// what a transaction's leaf in the Merkle tree is the hash of
+func merkleLeafData(transaction *tx.Transaction) []byte {
+	return bytes.Join([][]byte{transaction.ID, transaction.HashBytes()}, []byte{})
+}
+
+// the Merkle tree leaf of the transaction with this ID and the proof it's
+// in the block (see MerkleTree.Proof). Hashing the leaf up through the
+// proof gives the Merkle root that's in the block's header
+func (b *Block) TransactionProof(txID []byte) ([]byte, []MerkleProofStep, error) {
+	if b.Version == 0 {
+		return nil, nil, fmt.Errorf("Block %x is from before blocks had Merkle roots, there's nothing to prove against", b.Hash)
+	}
+	for idx, transaction := range b.Transactions {
+		if !bytes.Equal(transaction.ID, txID) {
+			continue
+		}
+		mt, err := b.merkleTree()
+		if err != nil {
+			return nil, nil, err
+		}
+		proof, err := mt.Proof(idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		leaf := sha256.Sum256(merkleLeafData(transaction))
+		return leaf[:], proof, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %x is not in block %x", tx.ErrUnknownTransaction, txID, b.Hash)
+}
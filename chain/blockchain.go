@@ -0,0 +1,945 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"blockchain/pow"
+	"blockchain/tx"
+	"blockchain/utils"
+
+	"github.com/boltdb/bolt"
+)
+
+const blocksBucket string = "blocks"
+
+// height (8 byte big endian) -> hash of the block at that height
+const heightsBucket string = "heights"
+const genesisBlockData string = "Genesis Block"
+
+// the bucket utxo.UTXOSet keeps the UTXO set in. The chain only
+// touches it to migrate a database, see migrateOriginalChain
+const utxoSetBucket string = "UTXOSet"
+
+// every node keeps its own copy of the chain, so when we're
+// given a node ID the database file is named after it (see DBFileName)
+const dbFile = "blockchain.db"
+const nodeDBFile = "blockchain_%s.db"
+
+// there's no block with that hash or at that height. It usually
+// comes wrapped with which one, so check for it with errors.Is
+var ErrBlockNotFound = errors.New("block not found")
+
+// a blockchain can be entirely defined by
+// 1. the hash of the latest block
+// 2. the connection to the database (which we only want one instance of)
+type Blockchain struct {
+	LatestHash []byte
+	DB         *bolt.DB
+	// height of the block LatestHash points at, -1 if there are no blocks.
+	// Kept next to LatestHash so asking for it can't fail
+	bestHeight int
+	// the Bolt transaction reorganize is switching chains in, while it
+	// runs. Reads go through it (see view), Bolt can't open another one
+	// in the same goroutine and the blocks it moved aren't committed yet
+	switching *bolt.Tx
+}
+
+// whatever has to follow the main chain block by block, like the UTXO
+// set (see utxo.UTXOSet). The chain calls these in the same Bolt
+// transaction that moves its tip, so if one gives back an error the
+// block doesn't go on (or come off) and nothing changes
+type ChainState interface {
+	// the block is going on top of the main chain. This is where
+	// spending something that isn't there (anymore) gets caught
+	ConnectBlock(dbtx *bolt.Tx, block *Block) error
+	// the block, which is the tip, is coming off the main chain
+	DisconnectBlock(dbtx *bolt.Tx, block *Block) error
+}
+
+// an iterator for looping thru the blocks in our blockchain in order
+// since bolt stores keys by byte-order which isn't the right order
+// blockchainIterator will go from latest block
+// to oldest (top to bottom so to speak)
+type BlockchainIterator struct {
+	currentHash []byte
+	bc          *Blockchain
+}
+
+// add a new block to the blockchain, takes in a list of transactions
+// to set equal to the "Transactions" field of
+// the block we're adding. This also saves it to the DB automatically,
+// and connects it to state in the same go
+func (bc *Blockchain) AddBlock(transactions []*tx.Transaction, state ChainState) (*Block, error) {
+
+	// try to find what the latest block was, we need it since its hash
+	// will be "previousHash" field for this new block we're making
+	// and the new block goes one above it
+	lastBlock, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// before we add it to the chain though, we must VERIFY the digital signature
+	// on all TXInputs for each Transaction, and make sure the coinbase
+	// doesn't pay the miner more than it should
+	lastHeader := lastBlock.Header()
+	medianTime, err := bc.MedianTimePast(&lastHeader)
+	if err != nil {
+		return nil, err
+	}
+	err = bc.verifyBlockTransactions(transactions, lastBlock.Height+1, medianTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// the chain decides how hard this block has to be to mine
+	bits, err := bc.RequiredBits(&lastHeader)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := bc.NextTimestamp(&lastHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	// make the new block
+	b, err := NewBlock(transactions, lastBlock.Hash, lastBlock.Height+1, bits, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// write the hash of this new block into DB as latest hash. If state
+	// won't take it (it spends something that's spent already) none of it happens
+	err = bc.DB.Update(func(dbtx *bolt.Tx) error {
+		if err := storeBlock(dbtx, b); err != nil {
+			return err
+		}
+		return connectBlock(dbtx, b, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// also update the blockchain struct accordingly
+	bc.setTip(b)
+	return b, nil
+}
+
+// this function is weird in the sense that we're not actually
+// initializing a blockchain on each call. Instead, we're looking
+// in the DB to see if it exists. Only if it doesnt exist do we
+// initialize, so basically it only gets created once. The rest of the time
+// we're just getting the latest hash from the DB
+// and putting using that hash to return a Blockchain object for use
+// RULES:
+// 32-byte block-hash -> Block structure (serialized)
+// 'l' -> the hash of the last block in a chain (l for latest)
+// and in the heights bucket
+// 8-byte height -> hash of the block at that height
+// and the headers bucket has every block's header, see header.go
+func InitBlockchain(address, dbPath string) (*Blockchain, error) {
+
+	// hash of the tip of the blockchain (latest block)
+	var tip []byte
+
+	// first open database file
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// start read write transaction in Bolt
+	err = db.Update(func(dbtx *bolt.Tx) error {
+		if err := createBuckets(dbtx); err != nil {
+			return err
+		}
+		tip = dbtx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+
+		// if there's no latest hash
+		// then it means we haven't initialized the blockchain
+		// (aka it has no blocks and its probably our first run of this program)
+		// so make the genesis block and write it
+		// into the blockchain, also put it as last hash
+		if tip == nil {
+			// create coinbase transaction to put on genesis block
+			// the unlock key for this transaction is the address.
+			newTransaction, err := tx.NewCoinbaseTX(address, genesisBlockData, BlockSubsidy(0))
+			if err != nil {
+				return err
+			}
+			firstBlock, err := GenesisBlock(newTransaction)
+			if err != nil {
+				return err
+			}
+
+			err = putBlock(dbtx, firstBlock)
+			if err != nil {
+				return err
+			}
+			tip = firstBlock.Hash
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return newBlockchain(db, tip)
+}
+
+// like InitBlockchain, except we never make a genesis block. This is
+// what a node uses, since it should download the genesis block
+// (and everything after it) from its peers instead of making its own.
+// If the database is brand new, LatestHash will be nil
+func OpenBlockchain(dbPath string) (*Blockchain, error) {
+	var tip []byte
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(dbtx *bolt.Tx) error {
+		if err := createBuckets(dbtx); err != nil {
+			return err
+		}
+		tip = dbtx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return newBlockchain(db, tip)
+}
+
+// makes the buckets a brand new database needs. A database from the first
+// version of the program only has the blocks bucket, so its chain gets
+// migrated in the same go (see migrateOriginalChain)
+func createBuckets(dbtx *bolt.Tx) error {
+	blocks, err := dbtx.CreateBucketIfNotExists([]byte(blocksBucket))
+	if err != nil {
+		return err
+	}
+	migrate := dbtx.Bucket([]byte(heightsBucket)) == nil && blocks.Get([]byte("l")) != nil
+	for _, name := range []string{heightsBucket, headersBucket} {
+		if _, err := dbtx.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return err
+		}
+	}
+	if !migrate {
+		return nil
+	}
+	return migrateOriginalChain(dbtx)
+}
+
+// the first version of the program stored blocks without a height,
+// difficulty or Merkle root, and hashed them with their transactions gob
+// encoded, which doesn't come out the same from one run to the next. So
+// their hashes can't be checked anymore, and the blocks are kept the way
+// they are as version 0 (see Verify). Walking back from "l" gives every
+// block its height, which goes in the height index, and the difficulty it
+// was mined at. Their headers go in with the hash the block already had
+// (BlockHeader.OriginalHash), so new blocks can go on top of them, and
+// the UTXO set gets built again
+func migrateOriginalChain(dbtx *bolt.Tx) error {
+	blocks := dbtx.Bucket([]byte(blocksBucket))
+
+	var original []*Block
+	for hash := blocks.Get([]byte("l")); len(hash) != 0; {
+		data := blocks.Get(hash)
+		if data == nil {
+			return fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+		}
+		block, err := DeserializeBlock(data)
+		if err != nil {
+			return err
+		}
+		if block.Version != 0 {
+			return fmt.Errorf("Block %x has version %d, the first version of the program only made version 0", block.Hash, block.Version)
+		}
+		original = append(original, block)
+		hash = block.PrevBlockHash
+	}
+
+	// the genesis block is the last one we got to
+	for height := 0; height < len(original); height++ {
+		block := original[len(original)-1-height]
+		block.Height = height
+		// it never changed back then
+		block.Bits = targetBits
+		if err := putBlock(dbtx, block); err != nil {
+			return err
+		}
+	}
+
+	// its UTXO set kept a transaction's unspent outputs without their
+	// indexes, so once one got spent the ones after it moved down.
+	// Building it again from the blocks gives them their indexes
+	unspentTXs := make(map[string]tx.TXOutputs)
+	spentTXOs := make(map[string][]int)
+	for _, block := range original {
+		addUnspentTXOs(block, unspentTXs, spentTXOs)
+	}
+	if err := dbtx.DeleteBucket([]byte(utxoSetBucket)); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	bucket, err := dbtx.CreateBucket([]byte(utxoSetBucket))
+	if err != nil {
+		return err
+	}
+	for txID, outputs := range unspentTXs {
+		key, err := hex.DecodeString(txID)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(key, outputs.Serialize()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makes the Blockchain struct for an opened database whose latest
+// block is tip, looking up the tip's height on the way
+func newBlockchain(db *bolt.DB, tip []byte) (*Blockchain, error) {
+	bc := &Blockchain{
+		DB:         db,
+		bestHeight: -1,
+	}
+	if len(tip) == 0 {
+		return bc, nil
+	}
+
+	block, err := bc.GetBlock(tip)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bc.setTip(block)
+	return bc, nil
+}
+
+// points the struct at a new latest block, call this
+// whenever "l" changes in the database
+func (bc *Blockchain) setTip(block *Block) {
+	bc.LatestHash = block.Hash
+	bc.bestHeight = block.Height
+}
+
+// writes the block and its header into the DB, makes it the latest
+// block and records its height in the height index
+func putBlock(tx *bolt.Tx, block *Block) error {
+	if err := storeBlock(tx, block); err != nil {
+		return err
+	}
+	return setMainTip(tx, block)
+}
+
+// puts an already stored block on top of the main chain,
+// with state following along in the same Bolt transaction
+func connectBlock(dbtx *bolt.Tx, block *Block, state ChainState) error {
+	if err := state.ConnectBlock(dbtx, block); err != nil {
+		return err
+	}
+	return setMainTip(dbtx, block)
+}
+
+// writes the block and its header into the DB without
+// putting it on the main chain, see ImportBlock
+func storeBlock(tx *bolt.Tx, block *Block) error {
+	header := block.Header()
+	if err := putHeader(tx, &header); err != nil {
+		return err
+	}
+	bucket := tx.Bucket([]byte(blocksBucket))
+	return bucket.Put(block.Hash, block.Serialize())
+}
+
+// makes an already stored block the latest one, and the
+// one at its height in the height index
+func setMainTip(tx *bolt.Tx, block *Block) error {
+	bucket := tx.Bucket([]byte(blocksBucket))
+	err := bucket.Put([]byte("l"), block.Hash)
+	if err != nil {
+		return err
+	}
+
+	heights := tx.Bucket([]byte(heightsBucket))
+	return heights.Put(utils.IntToBuffer(int64(block.Height)), block.Hash)
+}
+
+// where the database of the node with this ID lives inside dataDir.
+// An empty dataDir means the working directory
+func DBFileName(dataDir, nodeID string) string {
+	if nodeID == "" {
+		return filepath.Join(dataDir, dbFile)
+	}
+	return filepath.Join(dataDir, fmt.Sprintf(nodeDBFile, nodeID))
+}
+
+// runs fn in a read only Bolt transaction, or in the one
+// reorganize is in the middle of
+func (bc *Blockchain) view(fn func(*bolt.Tx) error) error {
+	if bc.switching != nil {
+		return fn(bc.switching)
+	}
+	return bc.DB.View(fn)
+}
+
+// opens (or creates) the bolt database at path, making its
+// directory first since bolt won't do that for us
+func openDB(path string) (*bolt.DB, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	return bolt.Open(path, 0600, nil)
+}
+
+// function to make a blockchain iterator
+// sort of "captures" a blockchain in a certain state so to speak
+func (bc *Blockchain) Iterator() *BlockchainIterator {
+	return &BlockchainIterator{
+		currentHash: bc.LatestHash,
+		bc:          bc,
+	}
+}
+
+// returns the block pointed to by the blockchainIterator
+// this is found by comparing the currentHash field
+// it also has the side effect of moving the blockchainIterator
+// to point to the next Block
+func (bci *BlockchainIterator) Next() (*Block, error) {
+	var block *Block
+
+	err := bci.bc.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(blocksBucket))
+		dbBlock := bucket.Get([]byte(bci.currentHash))
+		if dbBlock == nil {
+			return fmt.Errorf("%w: %x", ErrBlockNotFound, bci.currentHash)
+		}
+		var err error
+		block, err = DeserializeBlock(dbBlock)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	bci.currentHash = block.PrevBlockHash
+	return block, nil
+}
+
+// kinda like FindUnspentTransactions but instead there's no argument
+// and we don't check if the output belongs to a certain person.
+// Outputs that can never be spent are left out.
+// The UTXO set is built from this
+func (bc *Blockchain) FindAllUnspentTXOs() (map[string]tx.TXOutputs, error) {
+	unspentTXs := make(map[string]tx.TXOutputs)
+
+	// a node that hasn't synced yet has nothing to look through
+	if len(bc.LatestHash) == 0 {
+		return unspentTXs, nil
+	}
+
+	// map from string to int slice
+	// or transaction ID to index of spent outputs
+	spentTXOs := make(map[string][]int)
+	bci := bc.Iterator()
+	for {
+		block, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		addUnspentTXOs(block, unspentTXs, spentTXOs)
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return unspentTXs, nil
+}
+
+// adds the outputs of block's transactions that aren't in spentTXOs to
+// unspentTXs, and the outputs they spend to spentTXOs. Going from the
+// latest block down to the genesis block leaves the unspent ones
+func addUnspentTXOs(block *Block, unspentTXs map[string]tx.TXOutputs, spentTXOs map[string][]int) {
+	for _, transaction := range block.Transactions {
+		txID := hex.EncodeToString(transaction.ID)
+		txoutputs := tx.TXOutputs{}
+	Outputs:
+		for outIdx, out := range transaction.Vout {
+			// nobody can spend these, so they never count as unspent
+			if out.IsUnspendable() {
+				continue
+			}
+			if spentTXOs[txID] != nil {
+				for _, spentOut := range spentTXOs[txID] {
+					if spentOut == outIdx {
+						continue Outputs
+					}
+				}
+			}
+			txoutputs.Add(outIdx, out)
+			unspentTXs[txID] = txoutputs
+		}
+
+		if !transaction.IsCoinbase() {
+			for _, in := range transaction.Vin {
+				inTxID := hex.EncodeToString(in.Txid)
+				spentTXOs[inTxID] = append(spentTXOs[inTxID], in.OutputIdx)
+			}
+		}
+	}
+}
+
+// every public key hash that has ever been sent coins on this chain,
+// spent or not. Hex encoded so it can be a map key
+func (bc *Blockchain) ReceivedPubKeyHashes() (map[string]bool, error) {
+	received := make(map[string]bool)
+	if len(bc.LatestHash) == 0 {
+		return received, nil
+	}
+
+	bci := bc.Iterator()
+	for {
+		block, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+		for _, transaction := range block.Transactions {
+			for _, out := range transaction.Vout {
+				received[hex.EncodeToString(out.PublicKeyHash)] = true
+			}
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return received, nil
+}
+
+// gets a certain transaction given a transaction ID
+func (bc *Blockchain) FindTransaction(id []byte) (tx.Transaction, error) {
+	transaction, _, err := bc.FindTransactionBlock(id)
+	return transaction, err
+}
+
+// like FindTransaction but also gives back the block the transaction is in.
+// does this simply by using the Iterator and going through all the blocks
+func (bc *Blockchain) FindTransactionBlock(id []byte) (tx.Transaction, *Block, error) {
+	if len(bc.LatestHash) == 0 {
+		return tx.Transaction{}, nil, fmt.Errorf("%w: %x", tx.ErrUnknownTransaction, id)
+	}
+
+	it := bc.Iterator()
+	for {
+		block, err := it.Next()
+		if err != nil {
+			return tx.Transaction{}, nil, err
+		}
+		for _, transaction := range block.Transactions {
+			if bytes.Compare(id, transaction.ID) == 0 {
+				return *transaction, block, nil
+			}
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return tx.Transaction{}, nil, fmt.Errorf("%w: %x", tx.ErrUnknownTransaction, id)
+}
+
+// finds the transaction that anchored data (see tx.NewDataOutput) and the
+// block it's in. If it was anchored more than once this is the oldest,
+// since that's the one that shows how long the data has been around
+func (bc *Blockchain) FindAnchor(data []byte) (tx.Transaction, *Block, error) {
+	var found tx.Transaction
+	var foundBlock *Block
+	if len(bc.LatestHash) == 0 {
+		return found, nil, fmt.Errorf("%w: nothing anchors %x", ErrBlockNotFound, data)
+	}
+
+	it := bc.Iterator()
+	for {
+		block, err := it.Next()
+		if err != nil {
+			return found, nil, err
+		}
+		for _, transaction := range block.Transactions {
+			for _, out := range transaction.Vout {
+				if payload, ok := out.Data(); ok && bytes.Equal(payload, data) {
+					found, foundBlock = *transaction, block
+				}
+			}
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	if foundBlock == nil {
+		return found, nil, fmt.Errorf("%w: nothing anchors %x", ErrBlockNotFound, data)
+	}
+	return found, foundBlock, nil
+}
+
+// the fee of a transaction is what its inputs are worth minus what its
+// outputs are worth. Finds each input's output on the chain to get its value
+func (bc *Blockchain) transactionFee(transaction *tx.Transaction) (int, error) {
+	if transaction.IsCoinbase() {
+		return 0, nil
+	}
+
+	fee := 0
+	for _, vin := range transaction.Vin {
+		prevTX, err := bc.FindTransaction(vin.Txid)
+		if err != nil {
+			return 0, err
+		}
+		if vin.OutputIdx < 0 || vin.OutputIdx >= len(prevTX.Vout) {
+			return 0, fmt.Errorf("%w: %x has no output %d", tx.ErrUnknownTransaction, vin.Txid, vin.OutputIdx)
+		}
+		fee += prevTX.Vout[vin.OutputIdx].Value
+	}
+	for _, vout := range transaction.Vout {
+		fee -= vout.Value
+	}
+	return fee, nil
+}
+
+// adds up the fees of a list of transactions, which is
+// what the miner of a block containing them can claim
+func (bc *Blockchain) TotalFees(transactions []*tx.Transaction) (int, error) {
+	total := 0
+	for _, transaction := range transactions {
+		fee, err := bc.transactionFee(transaction)
+		if err != nil {
+			return 0, err
+		}
+		if fee < 0 {
+			return 0, fmt.Errorf("Transaction %x spends %d more than its inputs are worth", transaction.ID, -fee)
+		}
+		total += fee
+	}
+	return total, nil
+}
+
+// what can be checked about a block's transactions without looking at
+// the chain, so side chain blocks get checked this far before they're
+// stored. There has to be at least one, every transaction's ID has to be
+// its hash, no output can be worth less than nothing, and no transaction
+// can be in there twice. Repeating the last transactions gives the same
+// Merkle root, so a block like that would have the same hash as the real one.
+// No two inputs can spend the same output either, whether an output is
+// still unspent at all is up to the ChainState the block goes on with
+func checkBlockTransactions(transactions []*tx.Transaction) error {
+	if len(transactions) == 0 {
+		return fmt.Errorf("Block has no transactions")
+	}
+	seen := make(map[string]bool)
+	spent := make(map[string]bool)
+	for _, transaction := range transactions {
+		if !transaction.IsCoinbase() {
+			for _, vin := range transaction.Vin {
+				outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.OutputIdx)
+				if spent[outpoint] {
+					return fmt.Errorf("Block spends %s more than once", outpoint)
+				}
+				spent[outpoint] = true
+			}
+		}
+		if err := transaction.CheckID(); err != nil {
+			return err
+		}
+		txID := hex.EncodeToString(transaction.ID)
+		if seen[txID] {
+			return fmt.Errorf("Block has transaction %s more than once", txID)
+		}
+		seen[txID] = true
+		if _, err := transaction.OutputTotal(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checks the transactions that are about to go in the block at height,
+// on top of blocks with the median time medianTime. Besides checkBlockTransactions,
+// every signature has to be right, every lock has to have passed, there can
+// only be one coinbase, and it can pay at most the subsidy plus the fees of
+// the other transactions
+func (bc *Blockchain) verifyBlockTransactions(transactions []*tx.Transaction, height int, medianTime int64) error {
+	if err := checkBlockTransactions(transactions); err != nil {
+		return err
+	}
+
+	coinbaseTotal := 0
+	coinbases := 0
+	for _, transaction := range transactions {
+		if err := bc.VerifyTransaction(transaction); err != nil {
+			return fmt.Errorf("Transaction %x: %w", transaction.ID, err)
+		}
+		if err := bc.checkLocks(transaction, height, medianTime); err != nil {
+			return fmt.Errorf("Transaction %x: %w", transaction.ID, err)
+		}
+		if transaction.IsCoinbase() {
+			coinbases++
+			// checkBlockTransactions made sure this can't fail
+			value, _ := transaction.OutputTotal()
+			coinbaseTotal += value
+		}
+	}
+	if coinbases > 1 {
+		return fmt.Errorf("Block has %d coinbase transactions", coinbases)
+	}
+
+	fees, err := bc.TotalFees(transactions)
+	if err != nil {
+		return err
+	}
+	reward := BlockSubsidy(height)
+	if coinbaseTotal > reward+fees {
+		return fmt.Errorf("Coinbase claims %d but the subsidy plus fees is only %d", coinbaseTotal, reward+fees)
+	}
+	return nil
+}
+
+// this verifies a digital signature on a transaction. Gives back
+// tx.ErrUnknownTransaction if an input spends something that isn't on
+// the chain, or tx.ErrInvalidSignature if a signature is wrong
+func (bc *Blockchain) VerifyTransaction(transaction *tx.Transaction) error {
+	// coinbase transactions don't spend anything, so nothing to look up
+	if transaction.IsCoinbase() {
+		return nil
+	}
+
+	prevTXs := make(map[string]tx.Transaction)
+	for _, vin := range transaction.Vin {
+		prevTX, err := bc.FindTransaction(vin.Txid)
+		if err != nil {
+			return err
+		}
+		transactionReferenced := hex.EncodeToString(prevTX.ID)
+		prevTXs[transactionReferenced] = prevTX
+	}
+	return transaction.Verify(prevTXs)
+}
+
+// checks that transaction can go in the next block on top of the chain.
+// Its lock time has to have passed, and so has the relative lock of every
+// output it spends. Gives back tx.ErrLocked if it has to wait
+func (bc *Blockchain) CheckLocks(transaction *tx.Transaction) error {
+	height, medianTime := 0, int64(0)
+	if len(bc.LatestHash) != 0 {
+		tip, err := bc.GetHeader(bc.LatestHash)
+		if err != nil {
+			return err
+		}
+		medianTime, err = bc.MedianTimePast(tip)
+		if err != nil {
+			return err
+		}
+		height = tip.Height + 1
+	}
+	return bc.checkLocks(transaction, height, medianTime)
+}
+
+// CheckLocks for the block at height, on top of blocks with the median
+// time medianTime (see MedianTimePast). Relative locks count from the
+// block the spent output is in
+func (bc *Blockchain) checkLocks(transaction *tx.Transaction, height int, medianTime int64) error {
+	if !transaction.IsFinal(height, medianTime) {
+		return fmt.Errorf("%w until %s", tx.ErrLocked, tx.DescribeLockTime(transaction.LockTime))
+	}
+	if transaction.IsCoinbase() {
+		return nil
+	}
+
+	for _, vin := range transaction.Vin {
+		prevTX, block, err := bc.FindTransactionBlock(vin.Txid)
+		if err != nil {
+			return err
+		}
+		if vin.OutputIdx < 0 || vin.OutputIdx >= len(prevTX.Vout) {
+			return fmt.Errorf("%w: %x has no output %d", tx.ErrUnknownTransaction, vin.Txid, vin.OutputIdx)
+		}
+		unlocksAt := block.Height + prevTX.Vout[vin.OutputIdx].RelativeLock
+		if height < unlocksAt {
+			return fmt.Errorf("%w: output %d of %x can't be spent until block %d", tx.ErrLocked, vin.OutputIdx, vin.Txid, unlocksAt)
+		}
+	}
+	return nil
+}
+
+// the height of the latest block, where the genesis block is at height 0.
+// An empty chain (a node that hasn't synced yet) has height -1
+func (bc *Blockchain) GetBestHeight() int {
+	return bc.bestHeight
+}
+
+// looks up the block at a certain height using the height index,
+// so we don't have to walk down the chain to find it
+func (bc *Blockchain) GetBlockByHeight(height int) (*Block, error) {
+	var hash []byte
+
+	bc.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(heightsBucket))
+		if bucket != nil {
+			hash = bucket.Get(utils.IntToBuffer(int64(height)))
+		}
+		return nil
+	})
+
+	if hash == nil {
+		return nil, fmt.Errorf("%w: nothing at height %d, the best height is %d", ErrBlockNotFound, height, bc.GetBestHeight())
+	}
+	return bc.GetBlock(hash)
+}
+
+// checks whether we have a block with this hash stored
+func (bc *Blockchain) HasBlock(hash []byte) bool {
+	found := false
+	if len(hash) == 0 {
+		return found
+	}
+	bc.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(blocksBucket))
+		found = bucket.Get(hash) != nil
+		return nil
+	})
+	return found
+}
+
+// gets a block given its hash
+func (bc *Blockchain) GetBlock(hash []byte) (*Block, error) {
+	var block *Block
+
+	err := bc.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(blocksBucket))
+		blockData := bucket.Get(hash)
+		if blockData == nil {
+			return fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+		}
+		var err error
+		block, err = DeserializeBlock(blockData)
+		return err
+	})
+
+	return block, err
+}
+
+// stores a block that somebody else mined (as opposed to AddBlock, which
+// mines a new one). A block on top of our latest block goes on the main
+// chain. A block on top of any other block we have goes on a side chain,
+// and if that side chain ends up with more work than the main chain we
+// switch to it (see reorganize). state follows every block that goes on
+// or comes off the main chain. The ChainUpdate says which ones did, so
+// the mempool can follow too
+func (bc *Blockchain) ImportBlock(block *Block, state ChainState) (*ChainUpdate, error) {
+	update := &ChainUpdate{}
+	if bc.HasBlock(block.Hash) {
+		return update, nil
+	}
+
+	var prev *Block
+	if len(block.PrevBlockHash) == 0 {
+		if len(bc.LatestHash) != 0 {
+			return nil, fmt.Errorf("Block is a genesis block and we already have one")
+		}
+	} else {
+		var err error
+		prev, err = bc.GetBlock(block.PrevBlockHash)
+		if err != nil {
+			return nil, fmt.Errorf("Block does not build on any block we have: %w", err)
+		}
+	}
+
+	// the height isn't part of the hash, so make sure nobody lied about it
+	height := 0
+	if prev != nil {
+		height = prev.Height + 1
+	}
+	if block.Height != height {
+		return nil, fmt.Errorf("Block claims height %d but should be %d", block.Height, height)
+	}
+
+	header := block.Header()
+	if err := pow.CheckBits(header.Bits); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header.Hash(), block.Hash) {
+		return nil, fmt.Errorf("Block's hash does not match its header")
+	}
+	if err := bc.validateHeader(&header); err != nil {
+		return nil, err
+	}
+	if err := checkBlockTransactions(block.Transactions); err != nil {
+		return nil, err
+	}
+	// the header only stands for the transactions if the root matches them
+	merkleRoot, err := block.HashTransactions()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(block.MerkleRoot, merkleRoot) {
+		return nil, fmt.Errorf("Block's Merkle root does not match its transactions")
+	}
+
+	if prev == nil || bytes.Equal(prev.Hash, bc.LatestHash) {
+		var medianTime int64
+		if prev != nil {
+			prevHeader := prev.Header()
+			medianTime, err = bc.MedianTimePast(&prevHeader)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := bc.verifyBlockTransactions(block.Transactions, block.Height, medianTime); err != nil {
+			return nil, err
+		}
+
+		err := bc.DB.Update(func(dbtx *bolt.Tx) error {
+			if err := storeBlock(dbtx, block); err != nil {
+				return err
+			}
+			return connectBlock(dbtx, block, state)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bc.setTip(block)
+		update.Connected = []*Block{block}
+		return update, nil
+	}
+
+	// the rest of the checks on a side chain block's transactions wait
+	// until the side chain becomes the main chain, since they depend
+	// on what's on the chain before them
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
+		return storeBlock(tx, block)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tip, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		return nil, err
+	}
+	more, err := bc.moreWork(block, tip)
+	if err != nil || !more {
+		return update, err
+	}
+	return bc.reorganize(block, state)
+}
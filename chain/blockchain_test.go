@@ -0,0 +1,152 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"blockchain/tx"
+	"blockchain/wallet"
+
+	"github.com/boltdb/bolt"
+)
+
+// a database the way the first version of the program left it: blocks
+// without heights, difficulty or Merkle roots, only the blocks bucket
+// and a UTXO set keeping outputs by position
+func TestOpenMigratesOriginalChain(t *testing.T) {
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := string(w.GetAddress())
+	pubKeyHash := wallet.HashPubKey(w.PublicKey)
+
+	coinbase := func(data string) *tx.Transaction {
+		coinbase, err := tx.NewCoinbaseTX(address, data, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return coinbase
+	}
+	// two outputs, and only the first one gets spent
+	split := &tx.Transaction{
+		Vin:  []tx.TXInput{{Txid: []byte("genesis coinbase"), OutputIdx: 0}},
+		Vout: []tx.TXOutput{{Value: 4, PublicKeyHash: pubKeyHash}, {Value: 6, PublicKeyHash: pubKeyHash}},
+	}
+	split.ID = []byte("split")
+	spend := &tx.Transaction{
+		Vin:  []tx.TXInput{{Txid: split.ID, OutputIdx: 0}},
+		Vout: []tx.TXOutput{{Value: 4, PublicKeyHash: pubKeyHash}},
+	}
+	spend.ID = []byte("spend")
+	genesisCoinbase := coinbase("genesis")
+	genesisCoinbase.ID = split.Vin[0].Txid
+
+	var blocks []*Block
+	var prevHash []byte
+	for i, transactions := range [][]*tx.Transaction{{genesisCoinbase}, {split, coinbase("1")}, {spend, coinbase("2")}} {
+		hash := sha256.Sum256([]byte{byte(i)})
+		block := &Block{Timestamp: int64(1000 + i), Transactions: transactions, PrevBlockHash: prevHash, Hash: hash[:]}
+		blocks = append(blocks, block)
+		prevHash = block.Hash
+	}
+	tip := blocks[len(blocks)-1]
+
+	dbPath := filepath.Join(t.TempDir(), "blockchain.db")
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(dbtx *bolt.Tx) error {
+		bucket, err := dbtx.CreateBucket([]byte(blocksBucket))
+		if err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			if err := bucket.Put(block.Hash, block.Serialize()); err != nil {
+				return err
+			}
+		}
+		if err := bucket.Put([]byte("l"), tip.Hash); err != nil {
+			return err
+		}
+		// output 1 of split slid down to position 0
+		utxos, err := dbtx.CreateBucket([]byte(utxoSetBucket))
+		if err != nil {
+			return err
+		}
+		old := tx.TXOutputs{Outputs: split.Vout[1:]}
+		return utxos.Put(split.ID, old.Serialize())
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := OpenBlockchain(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.DB.Close() })
+
+	if !bytes.Equal(bc.LatestHash, tip.Hash) || bc.GetBestHeight() != 2 {
+		t.Fatalf("tip is %x at %d, expected %x at 2", bc.LatestHash, bc.GetBestHeight(), tip.Hash)
+	}
+	for height, block := range blocks {
+		got, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Hash, block.Hash) || got.Height != height || got.Bits != targetBits {
+			t.Errorf("height %d has %x at %d with %d bits", height, got.Hash, got.Height, got.Bits)
+		}
+		header, err := bc.GetHeader(block.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(header.Hash(), block.Hash) {
+			t.Errorf("header at %d hashes to %x, expected %x", height, header.Hash(), block.Hash)
+		}
+	}
+	best, err := bc.BestHeader()
+	if err != nil || best == nil || !bytes.Equal(best.Hash(), tip.Hash) {
+		t.Fatalf("best header isn't the tip (%v)", err)
+	}
+	if err := bc.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = bc.DB.View(func(dbtx *bolt.Tx) error {
+		data := dbtx.Bucket([]byte(utxoSetBucket)).Get(split.ID)
+		if data == nil {
+			return fmt.Errorf("split is gone from the UTXO set")
+		}
+		outputs, err := tx.DeserializeOutputs(data)
+		if err != nil {
+			return err
+		}
+		if output, found := outputs.Find(1); !found || output.Value != 6 {
+			t.Errorf("expected output 1 of split worth 6, got %+v", outputs)
+		}
+		if _, found := outputs.Find(0); found {
+			t.Error("output 0 of split is spent but still in the UTXO set")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// opening it again doesn't migrate anything twice
+	bc.DB.Close()
+	bc, err = OpenBlockchain(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.GetBestHeight() != 2 {
+		t.Fatalf("best height is %d after opening again", bc.GetBestHeight())
+	}
+}
@@ -0,0 +1,161 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"blockchain/pow"
+)
+
+// the genesis block needs 16 zero bits or more to accept a hash as OK.
+// After that the chain adjusts the difficulty by itself, see RequiredBits
+const targetBits = 16
+
+// how many seconds we'd like there to be between blocks
+const targetBlockTime = 10
+
+// the difficulty is recalculated every retargetInterval blocks, using
+// how long the blocks since the last recalculation took to mine
+const retargetInterval = 10
+
+// a block's timestamp has to be later than the median of the timestamps
+// of this many blocks before it. The median moves slowly, so one miner
+// can't drag it back, and it only goes forward
+const medianTimeSpan = 11
+
+// how far past our clock a block's timestamp can be, in seconds.
+// Clocks are never quite right, but a miner can't go much further
+// to make the last blocks look slower than they were
+const maxFutureBlockTime = 2 * 60 * 60
+
+// a header's timestamp is too early or too late, see validateHeader
+var ErrBadTimestamp = errors.New("bad block timestamp")
+
+// checks the proof of work of a block. The hash has to be below the
+// target, and the block also has to use the difficulty that the chain
+// says it should have, otherwise a miner could just pick an easy one
+func (bc *Blockchain) ValidateProofOfWork(block *Block) bool {
+	header := block.Header()
+	return bc.validateHeader(&header) == nil
+}
+
+// everything ValidateProofOfWork checks, and that the header has the
+// version new blocks get. Only the blocks of a migrated database have
+// version 0, and nobody can check their hashes. The timestamp has to
+// be after the median time of the blocks before it and at most
+// maxFutureBlockTime ahead of our clock, the difficulty goes by the
+// timestamps so miners don't get to pick them freely. Only needs
+// the headers before it, not any blocks
+func (bc *Blockchain) validateHeader(header *BlockHeader) error {
+	if header.Version != BlockVersion {
+		return fmt.Errorf("Header has version %d but blocks are made with %d", header.Version, BlockVersion)
+	}
+
+	var prev *BlockHeader
+	if len(header.PrevBlockHash) != 0 {
+		var err error
+		prev, err = bc.GetHeader(header.PrevBlockHash)
+		if err != nil {
+			return err
+		}
+	}
+	if limit := time.Now().Unix() + maxFutureBlockTime; header.Timestamp > limit {
+		return fmt.Errorf("%w: %d is more than %d seconds in the future", ErrBadTimestamp, header.Timestamp, maxFutureBlockTime)
+	}
+	medianTime, err := bc.MedianTimePast(prev)
+	if err != nil {
+		return err
+	}
+	if prev != nil && header.Timestamp <= medianTime {
+		return fmt.Errorf("%w: %d isn't after %d, the median time of the blocks before", ErrBadTimestamp, header.Timestamp, medianTime)
+	}
+
+	requiredBits, err := bc.RequiredBits(prev)
+	if err != nil {
+		return err
+	}
+	if header.Bits != requiredBits {
+		return fmt.Errorf("Header has difficulty %d but should have %d", header.Bits, requiredBits)
+	}
+
+	proof, err := pow.NewProofOfWork(header)
+	if err != nil {
+		return err
+	}
+	if !proof.Validate(header.Nonce) {
+		return fmt.Errorf("Header failed proof of work validation")
+	}
+	return nil
+}
+
+// works out the difficulty the block after prev has to have (prev is nil
+// for the genesis block). Most of the time this is just whatever prev had.
+// But every retargetInterval blocks we look at how long the last
+// retargetInterval blocks took, and if they came in a lot faster than
+// targetBlockTime we make blocks harder, or easier if they were slower
+func (bc *Blockchain) RequiredBits(prev *BlockHeader) (int, error) {
+	if prev == nil {
+		return targetBits, nil
+	}
+
+	height := prev.Height + 1
+	if height%retargetInterval != 0 {
+		return prev.Bits, nil
+	}
+
+	// walk back to the first block of this window. We follow the
+	// previous hashes instead of using the height index, since prev
+	// doesn't have to be on our main chain (or have its block yet)
+	first := prev
+	for i := 0; i < retargetInterval-1; i++ {
+		var err error
+		first, err = bc.GetHeader(first.PrevBlockHash)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	actual := prev.Timestamp - first.Timestamp
+	expected := int64(retargetInterval-1) * targetBlockTime
+	return pow.AdjustBits(prev.Bits, actual, expected), nil
+}
+
+// the median timestamp of the medianTimeSpan blocks ending in header
+// (fewer near the genesis block), 0 if header is nil. Time locks go by
+// this too, so a miner can't put a transaction in early by moving the
+// timestamp of its block forward
+func (bc *Blockchain) MedianTimePast(header *BlockHeader) (int64, error) {
+	var times []int64
+	for header != nil && len(times) < medianTimeSpan {
+		times = append(times, header.Timestamp)
+		if len(header.PrevBlockHash) == 0 {
+			break
+		}
+		var err error
+		header, err = bc.GetHeader(header.PrevBlockHash)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(times) == 0 {
+		return 0, nil
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2], nil
+}
+
+// the timestamp for a block we mine on top of prev: now, unless that's
+// not after the median time yet (blocks coming in faster than the clock ticks)
+func (bc *Blockchain) NextTimestamp(prev *BlockHeader) (int64, error) {
+	medianTime, err := bc.MedianTimePast(prev)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	if prev != nil && now <= medianTime {
+		now = medianTime + 1
+	}
+	return now, nil
+}
@@ -0,0 +1,76 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"blockchain/pow"
+)
+
+// mines a header on top of prev with the timestamp given
+func mineHeader(t *testing.T, prev *BlockHeader, timestamp int64) *BlockHeader {
+	t.Helper()
+	header := &BlockHeader{
+		Version:       BlockVersion,
+		PrevBlockHash: prev.Hash(),
+		MerkleRoot:    prev.MerkleRoot,
+		Timestamp:     timestamp,
+		Bits:          prev.Bits,
+		Height:        prev.Height + 1,
+	}
+	proof, err := pow.NewProofOfWork(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Nonce, _ = proof.Run()
+	return header
+}
+
+func TestTimestampHasToBeAfterMedianTime(t *testing.T) {
+	bc := newTestChain(t)
+	genesis, err := bc.GetHeader(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := genesis.Timestamp
+
+	for _, timestamp := range []int64{start - 100, start} {
+		if err := bc.AddHeader(mineHeader(t, genesis, timestamp)); !errors.Is(err, ErrBadTimestamp) {
+			t.Errorf("timestamp %d on top of %d: expected ErrBadTimestamp, got %v", timestamp, start, err)
+		}
+	}
+
+	// the median of start .. start+3 is start+2, so being
+	// later than the block right before isn't enough
+	prev := genesis
+	for i := int64(1); i <= 3; i++ {
+		header := mineHeader(t, prev, start+i)
+		if err := bc.AddHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		prev = header
+	}
+	if err := bc.AddHeader(mineHeader(t, prev, start+2)); !errors.Is(err, ErrBadTimestamp) {
+		t.Errorf("timestamp at the median time: expected ErrBadTimestamp, got %v", err)
+	}
+	if err := bc.AddHeader(mineHeader(t, prev, start+3)); err != nil {
+		t.Errorf("timestamp after the median time: %v", err)
+	}
+}
+
+func TestTimestampCantBeFarInTheFuture(t *testing.T) {
+	bc := newTestChain(t)
+	genesis, err := bc.GetHeader(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Unix() + maxFutureBlockTime + 60
+	if err := bc.AddHeader(mineHeader(t, genesis, future)); !errors.Is(err, ErrBadTimestamp) {
+		t.Errorf("timestamp %d seconds ahead: expected ErrBadTimestamp, got %v", future-time.Now().Unix(), err)
+	}
+	if err := bc.AddHeader(mineHeader(t, genesis, time.Now().Unix()+60)); err != nil {
+		t.Errorf("timestamp a minute ahead: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+
+	"blockchain/pow"
+	"blockchain/utils"
+
+	"github.com/boltdb/bolt"
+)
+
+// hash of a block -> its BlockHeader (serialized). Every block we have
+// has its header in here, but a header can be here before its block
+// is, since nodes download headers first and bodies after
+const headersBucket string = "headers"
+
+// the key of the best header, the top of the chain of headers.
+// It's the same as "l" unless we have headers we don't have blocks for yet
+const bestHeaderKey string = "h"
+
+// the version new blocks get. Version 0 is only for the blocks of a
+// database from the first version of the program, see migrateOriginalChain
+const BlockVersion = 1
+
+// everything about a block except its transactions, which the Merkle
+// root stands in for. This is what gets mined, and it's small enough
+// that a light client can keep every one of them
+type BlockHeader struct {
+	Version       int
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	Bits          int
+	Nonce         int
+	// like Block.Height, not part of the hash
+	Height int
+	// only version 0 headers have this, the hash their block was stored
+	// with. Those blocks were hashed with gob encoded transactions,
+	// which don't come out the same twice, so it can't be worked out again
+	OriginalHash []byte
+}
+
+// the header as bytes, with nonce being the miner's guess. The Merkle
+// root is already worked out, so mining doesn't have to hash every
+// transaction again for each nonce it tries
+func (h *BlockHeader) HeaderBytes(nonce int) []byte {
+	version := utils.IntToBuffer(int64(h.Version))
+	timestamp := utils.IntToBuffer(h.Timestamp)
+	target := utils.IntToBuffer(int64(h.Bits))
+	nonceBytes := utils.IntToBuffer(int64(nonce))
+
+	return bytes.Join([][]byte{version, timestamp, h.MerkleRoot, h.PrevBlockHash, target, nonceBytes}, []byte{})
+}
+
+// the difficulty the header was mined at, so it works as a pow.Header
+func (h *BlockHeader) TargetBits() int {
+	return h.Bits
+}
+
+// the hash of the header with the nonce it was mined with,
+// which is the hash of its block
+func (h *BlockHeader) Hash() []byte {
+	if h.Version == 0 {
+		return h.OriginalHash
+	}
+	return pow.Hash(h, h.Nonce)
+}
+
+// same as Block.Serialize, only fails if the struct itself is broken
+func (h *BlockHeader) Serialize() []byte {
+	var output bytes.Buffer
+	enc := gob.NewEncoder(&output)
+	err := enc.Encode(h)
+	if err != nil {
+		log.Panic("Encode err:", err)
+	}
+	return output.Bytes()
+}
+
+func DeserializeHeader(b []byte) (*BlockHeader, error) {
+	var header BlockHeader
+	dec := gob.NewDecoder(bytes.NewReader(b))
+	err := dec.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding header: %w", err)
+	}
+	return &header, nil
+}
+
+// stores a header, and makes it the best header if its chain has more
+// work than the best one's. On a tie the one we had first stays
+func putHeader(dbtx *bolt.Tx, header *BlockHeader) error {
+	bucket := dbtx.Bucket([]byte(headersBucket))
+	hash := header.Hash()
+	if err := bucket.Put(hash, header.Serialize()); err != nil {
+		return err
+	}
+
+	if best := bucket.Get([]byte(bestHeaderKey)); best != nil {
+		getHeader := headerGetter(bucket)
+		bestHeader, err := getHeader(best)
+		if err != nil {
+			return err
+		}
+		diff, err := workDifference(header, bestHeader, getHeader)
+		if err != nil {
+			return err
+		}
+		if diff.Sign() <= 0 {
+			return nil
+		}
+	}
+	return bucket.Put([]byte(bestHeaderKey), hash)
+}
+
+// GetHeader for when a Bolt transaction is open already
+func headerGetter(bucket *bolt.Bucket) func([]byte) (*BlockHeader, error) {
+	return func(hash []byte) (*BlockHeader, error) {
+		data := bucket.Get(hash)
+		if data == nil {
+			return nil, fmt.Errorf("%w: no header for %x", ErrBlockNotFound, hash)
+		}
+		return DeserializeHeader(data)
+	}
+}
+
+// gets a header given the hash of its block
+func (bc *Blockchain) GetHeader(hash []byte) (*BlockHeader, error) {
+	var header *BlockHeader
+
+	err := bc.view(func(dbtx *bolt.Tx) error {
+		data := dbtx.Bucket([]byte(headersBucket)).Get(hash)
+		if data == nil {
+			return fmt.Errorf("%w: no header for %x", ErrBlockNotFound, hash)
+		}
+		var err error
+		header, err = DeserializeHeader(data)
+		return err
+	})
+
+	return header, err
+}
+
+// checks whether we have the header of the block with this hash
+func (bc *Blockchain) HasHeader(hash []byte) bool {
+	found := false
+	if len(hash) == 0 {
+		return found
+	}
+	bc.view(func(dbtx *bolt.Tx) error {
+		found = dbtx.Bucket([]byte(headersBucket)).Get(hash) != nil
+		return nil
+	})
+	return found
+}
+
+// the top of the chain of headers with the most work,
+// nil if we don't have any yet
+func (bc *Blockchain) BestHeader() (*BlockHeader, error) {
+	var best []byte
+	bc.view(func(dbtx *bolt.Tx) error {
+		best = dbtx.Bucket([]byte(headersBucket)).Get([]byte(bestHeaderKey))
+		return nil
+	})
+	if best == nil {
+		return nil, nil
+	}
+	return bc.GetHeader(best)
+}
+
+// stores the header of a block somebody else mined, before (or instead
+// of) downloading the block itself. It has to build on top of a header
+// we have and have valid proof of work, which is everything about a
+// block that can be checked without its transactions. If its chain has
+// more work than our best header's it becomes the best header
+func (bc *Blockchain) AddHeader(header *BlockHeader) error {
+	// before anything else looks at it, nothing can do
+	// anything sensible with a difficulty like that
+	if err := pow.CheckBits(header.Bits); err != nil {
+		return err
+	}
+	hash := header.Hash()
+	if bc.HasHeader(hash) {
+		return nil
+	}
+
+	height := 0
+	if len(header.PrevBlockHash) == 0 {
+		best, err := bc.BestHeader()
+		if err != nil {
+			return err
+		}
+		if best != nil {
+			return fmt.Errorf("Header is a genesis header and we already have one")
+		}
+	} else {
+		prev, err := bc.GetHeader(header.PrevBlockHash)
+		if err != nil {
+			return fmt.Errorf("Header does not build on any header we have: %w", err)
+		}
+		height = prev.Height + 1
+	}
+	if header.Height != height {
+		return fmt.Errorf("Header claims height %d but should be %d", header.Height, height)
+	}
+	if err := bc.validateHeader(header); err != nil {
+		return err
+	}
+
+	return bc.DB.Update(func(dbtx *bolt.Tx) error {
+		return putHeader(dbtx, header)
+	})
+}
+
+// hashes of blocks going back from our best header, the first ten one by
+// one and then twice as far back each time, ending with the genesis block.
+// A peer finds the first one that's on its main chain, that's where our
+// chains split (or where we stopped), see HeadersAfter
+func (bc *Blockchain) Locator() ([][]byte, error) {
+	var locator [][]byte
+	header, err := bc.BestHeader()
+	if err != nil || header == nil {
+		return locator, err
+	}
+
+	step := 1
+	for {
+		locator = append(locator, header.Hash())
+		if len(header.PrevBlockHash) == 0 {
+			return locator, nil
+		}
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		// step back, but never past the genesis block
+		for i := 0; i < step && len(header.PrevBlockHash) != 0; i++ {
+			header, err = bc.GetHeader(header.PrevBlockHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// the headers of the main chain after the first block in locator that's
+// on it, at most max of them, oldest first. If none of them are on our
+// main chain (or locator is empty) they start at the genesis block
+func (bc *Blockchain) HeadersAfter(locator [][]byte, max int) ([]*BlockHeader, error) {
+	height := 0
+	for _, hash := range locator {
+		header, err := bc.GetHeader(hash)
+		if err != nil {
+			continue
+		}
+		onChain, err := bc.GetBlockByHeight(header.Height)
+		if err == nil && bytes.Equal(onChain.Hash, hash) {
+			height = header.Height + 1
+			break
+		}
+	}
+
+	var headers []*BlockHeader
+	for ; height <= bc.GetBestHeight() && len(headers) < max; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		header := block.Header()
+		headers = append(headers, &header)
+	}
+	return headers, nil
+}
+
+// the hashes of the blocks on the best header chain that we only have
+// headers for, oldest first. These are the ones to download
+func (bc *Blockchain) MissingBlocks() ([][]byte, error) {
+	var missing [][]byte
+	header, err := bc.BestHeader()
+	if err != nil || header == nil {
+		return missing, err
+	}
+
+	for {
+		hash := header.Hash()
+		if bc.HasBlock(hash) {
+			break
+		}
+		missing = append([][]byte{hash}, missing...)
+		if len(header.PrevBlockHash) == 0 {
+			break
+		}
+		header, err = bc.GetHeader(header.PrevBlockHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
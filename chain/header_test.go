@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"blockchain/pow"
+	"blockchain/wallet"
+)
+
+// a new chain in a temporary directory with just its genesis block
+func newTestChain(t *testing.T) *Blockchain {
+	t.Helper()
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := InitBlockchain(string(w.GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.DB.Close() })
+	return bc
+}
+
+func TestAddHeaderRejectsBitsOutOfRange(t *testing.T) {
+	bc := newTestChain(t)
+	genesis, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bits := range []int{300, 257, pow.MaxTargetBits + 1, pow.MinTargetBits - 1, 0, -5} {
+		header := genesis.Header()
+		header.PrevBlockHash = genesis.Hash
+		header.Height = 1
+		header.Bits = bits
+		if err := bc.AddHeader(&header); !errors.Is(err, pow.ErrInvalidBits) {
+			t.Errorf("%d bits: expected ErrInvalidBits, got %v", bits, err)
+		}
+
+		block := *genesis
+		block.PrevBlockHash = genesis.Hash
+		block.Height = 1
+		block.Bits = bits
+		block.Hash = header.Hash()
+		if _, err := bc.ImportBlock(&block, nil); !errors.Is(err, pow.ErrInvalidBits) {
+			t.Errorf("block with %d bits: expected ErrInvalidBits, got %v", bits, err)
+		}
+	}
+	if _, err := headerWork(300); !errors.Is(err, pow.ErrInvalidBits) {
+		t.Errorf("headerWork(300): expected ErrInvalidBits, got %v", err)
+	}
+}
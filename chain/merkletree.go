@@ -0,0 +1,145 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+type MerkleTree struct {
+	RootNode *MerkleNode
+	// how many leaves the tree was made from, not counting the copies
+	// that even out odd levels
+	leaves int
+}
+
+// each node of the Merkle tree has a left/right node, as well as some value
+// which is the hash of the concatenated values of its left and right nodes.
+type MerkleNode struct {
+	Left  *MerkleNode
+	Right *MerkleNode
+	Hash  []byte
+}
+
+// given two MerkleNodes and some data to add into the hash
+// this function returns a new MerkleNode
+// put in their words:
+// Every node contains some data.
+// When a node is a leaf, the data is passed from the outside
+// (a serialized transaction in our case).
+// When a node is linked to other nodes, it takes their
+// data and concatenates and hashes it.
+func NewMerkleNode(left, right *MerkleNode, data []byte) MerkleNode {
+	ret := MerkleNode{}
+
+	// so if left and right both have no data, just hash the data
+	if left == nil && right == nil {
+		hashval := sha256.Sum256(data)
+		ret.Hash = hashval[:]
+	} else {
+		// otherwise append the data from left and right
+		// then hash
+		prevHashes := append(left.Hash, right.Hash...)
+		hashval := sha256.Sum256(prevHashes)
+		ret.Hash = hashval[:]
+	}
+
+	ret.Left = left
+	ret.Right = right
+
+	return ret
+}
+
+// we pass in a list of 32-byte transaction IDs (hence the 2D byte slice).
+// When a level has an odd number of nodes the last one gets paired with a
+// copy of itself, like Bitcoin does. That means a list with its last
+// transaction (or last few) repeated has the same root, so the chain has
+// to reject blocks with the same transaction twice (see checkBlockTransactions).
+// A tree needs at least one leaf, there's nothing to make a root out of otherwise
+func NewMerkleTree(data [][]byte) (MerkleTree, error) {
+	if len(data) == 0 {
+		return MerkleTree{}, fmt.Errorf("a Merkle tree needs at least one leaf")
+	}
+	var LeafNodes []MerkleNode
+	for _, bytes := range data {
+		LeafNodes = append(LeafNodes, NewMerkleNode(nil, nil, bytes))
+	}
+
+	// Merkle Tree must have even number of leaf nodes
+	// so if its odd, duplicate the last one
+	if len(LeafNodes)%2 != 0 {
+		LeafNodes = append(LeafNodes, LeafNodes[len(LeafNodes)-1:]...)
+	}
+
+	for len(LeafNodes) != 1 {
+		// and the same goes for every level above
+		if len(LeafNodes)%2 != 0 {
+			LeafNodes = append(LeafNodes, LeafNodes[len(LeafNodes)-1:]...)
+		}
+		var nextLevel []MerkleNode
+		for i := 0; i < len(LeafNodes)/2; i++ {
+
+			newNode := NewMerkleNode(&LeafNodes[2*i], &LeafNodes[2*i+1], []byte{})
+			nextLevel = append(nextLevel, newNode)
+		}
+		LeafNodes = nextLevel
+	}
+	return MerkleTree{
+		RootNode: &LeafNodes[0],
+		leaves:   len(data),
+	}, nil
+}
+
+// one step of a Merkle proof: the hash next to the one we have so far,
+// and whether it goes on the left when the two get hashed together
+type MerkleProofStep struct {
+	Hash []byte
+	Left bool
+}
+
+// the hashes needed to get from leaf number index up to the root, bottom
+// first. Together with the leaf they're enough to show the leaf is part of
+// the tree without having any of the other leaves
+func (mt *MerkleTree) Proof(index int) ([]MerkleProofStep, error) {
+	if index < 0 || index >= mt.leaves {
+		return nil, fmt.Errorf("the tree has %d leaves, there's no leaf %d", mt.leaves, index)
+	}
+
+	// every leaf is the same number of levels down, and the bits of
+	// index say which way to go at each one (1 is right)
+	depth := 0
+	for node := mt.RootNode; node.Left != nil; node = node.Left {
+		depth++
+	}
+	var proof []MerkleProofStep
+	node := mt.RootNode
+	for level := depth - 1; level >= 0; level-- {
+		if index>>level&1 == 0 {
+			proof = append([]MerkleProofStep{{node.Right.Hash, false}}, proof...)
+			node = node.Left
+		} else {
+			proof = append([]MerkleProofStep{{node.Left.Hash, true}}, proof...)
+			node = node.Right
+		}
+	}
+	return proof, nil
+}
+
+// whether leaf (a leaf's hash, not the data it was made from) hashes up
+// through path to root. This only needs the proof and the root from a
+// block's header, so a light client can check a transaction is in a block
+// without downloading it
+func VerifyMerkleProof(root, leaf []byte, path []MerkleProofStep) bool {
+	hash := leaf
+	for _, step := range path {
+		var joined []byte
+		if step.Left {
+			joined = append(append(joined, step.Hash...), hash...)
+		} else {
+			joined = append(append(joined, hash...), step.Hash...)
+		}
+		sum := sha256.Sum256(joined)
+		hash = sum[:]
+	}
+	return bytes.Equal(hash, root)
+}
@@ -0,0 +1,305 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"blockchain/pow"
+	"blockchain/utils"
+
+	"github.com/boltdb/bolt"
+)
+
+// what happened to the main chain when a block was imported. Usually
+// that's just the block going on top, but when a side chain gets more
+// work than the main chain, the blocks since the two split come off
+// and the side chain's go on in their place (a reorganization)
+type ChainUpdate struct {
+	// blocks taken off the main chain, newest first
+	Disconnected []*Block
+	// blocks put on the main chain, oldest first. Empty if the
+	// block went on a side chain and it doesn't have more work
+	Connected []*Block
+}
+
+// the work that went into a block. Each extra bit halves the target,
+// so finding a hash takes 2^bits tries on average. Headers get checked
+// before they're stored, but a huge bits would make a huge number
+func headerWork(bits int) (*big.Int, error) {
+	if err := pow.CheckBits(bits); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits)), nil
+}
+
+// how much more work went into the chain ending in a than the one ending
+// in b (negative if less). Only the blocks since the last one they have in
+// common count, so this walks back to there using getHeader
+func workDifference(a, b *BlockHeader, getHeader func([]byte) (*BlockHeader, error)) (*big.Int, error) {
+	diff := new(big.Int)
+	for !bytes.Equal(a.Hash(), b.Hash()) {
+		if len(a.PrevBlockHash) == 0 && len(b.PrevBlockHash) == 0 {
+			return nil, fmt.Errorf("Chains don't have a genesis block in common")
+		}
+
+		if a.Height >= b.Height {
+			work, err := headerWork(a.Bits)
+			if err != nil {
+				return nil, err
+			}
+			diff.Add(diff, work)
+			a, err = getHeader(a.PrevBlockHash)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			work, err := headerWork(b.Bits)
+			if err != nil {
+				return nil, err
+			}
+			diff.Sub(diff, work)
+			b, err = getHeader(b.PrevBlockHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return diff, nil
+}
+
+// whether the chain ending in block a has more work than the one ending in b
+func (bc *Blockchain) moreWork(a, b *Block) (bool, error) {
+	headerA, headerB := a.Header(), b.Header()
+	diff, err := workDifference(&headerA, &headerB, bc.GetHeader)
+	if err != nil {
+		return false, err
+	}
+	return diff.Sign() > 0, nil
+}
+
+// switches the main chain over to the one ending in newTip. The blocks
+// since the split come off (newest first), then newTip's branch goes on
+// (oldest first) with each block's transactions checked against the chain
+// below it and state. All of it happens in one Bolt transaction, so if a
+// block turns out to be invalid (or the program stops halfway) none of it
+// happened and we're still on the chain we had. The invalid block and
+// everything we have on top of it gets thrown away
+func (bc *Blockchain) reorganize(newTip *Block, state ChainState) (*ChainUpdate, error) {
+	oldTip, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var disconnect, connect []*Block
+	a, b := oldTip, newTip
+	for !bytes.Equal(a.Hash, b.Hash) {
+		if a.Height >= b.Height {
+			disconnect = append(disconnect, a)
+			a, err = bc.GetBlock(a.PrevBlockHash)
+		} else {
+			connect = append([]*Block{b}, connect...)
+			b, err = bc.GetBlock(b.PrevBlockHash)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var invalid *Block
+	err = bc.DB.Update(func(dbtx *bolt.Tx) error {
+		bc.switching = dbtx
+		defer func() { bc.switching = nil }()
+
+		for _, block := range disconnect {
+			prev, err := bc.GetBlock(block.PrevBlockHash)
+			if err != nil {
+				return err
+			}
+			if err := disconnectBlock(dbtx, block, prev, state); err != nil {
+				// a block from before state could take blocks
+				// back out (see utxo.ErrNoUndoData)
+				return fmt.Errorf("Can't take block %x off the main chain, staying on ours: %w", block.Hash, err)
+			}
+			bc.setTip(prev)
+		}
+		for _, block := range connect {
+			tip, err := bc.GetHeader(bc.LatestHash)
+			if err != nil {
+				return err
+			}
+			medianTime, err := bc.MedianTimePast(tip)
+			if err != nil {
+				return err
+			}
+			err = bc.verifyBlockTransactions(block.Transactions, block.Height, medianTime)
+			if err == nil {
+				err = connectBlock(dbtx, block, state)
+			}
+			if err != nil {
+				invalid = block
+				return fmt.Errorf("Block %x on the chain with more work is invalid, staying on ours: %w", block.Hash, err)
+			}
+			bc.setTip(block)
+		}
+		return nil
+	})
+	if err != nil {
+		// nothing was committed, so the old tip is still the tip
+		bc.setTip(oldTip)
+		if invalid != nil {
+			if forgetErr := bc.forgetBranch(invalid.Hash); forgetErr != nil {
+				return nil, forgetErr
+			}
+		}
+		return nil, err
+	}
+
+	return &ChainUpdate{Disconnected: disconnect, Connected: connect}, nil
+}
+
+// takes the latest block off the main chain, the block before it becomes
+// the latest. The block itself stays stored, now on a side chain. A nil
+// state is only for RollbackTip, when there's nothing that can follow
+func (bc *Blockchain) disconnectTip(state ChainState) error {
+	tip, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		return err
+	}
+	prev, err := bc.GetBlock(tip.PrevBlockHash)
+	if err != nil {
+		return err
+	}
+
+	err = bc.DB.Update(func(dbtx *bolt.Tx) error {
+		return disconnectBlock(dbtx, tip, prev, state)
+	})
+	if err != nil {
+		return err
+	}
+	bc.setTip(prev)
+	return nil
+}
+
+// takes tip off the main chain in dbtx, with state following
+// along unless it's nil. prev is the block before it
+func disconnectBlock(dbtx *bolt.Tx, tip, prev *Block, state ChainState) error {
+	if state != nil {
+		if err := state.DisconnectBlock(dbtx, tip); err != nil {
+			return err
+		}
+	}
+	heights := dbtx.Bucket([]byte(heightsBucket))
+	if err := heights.Delete(utils.IntToBuffer(int64(tip.Height))); err != nil {
+		return err
+	}
+	return setMainTip(dbtx, prev)
+}
+
+// removes an invalid block and every block and header we have on top of
+// it, so none of them can be picked again. The invalid one's chain was
+// probably the best header's, so the best header gets worked out again
+// from the headers that are left
+func (bc *Blockchain) forgetBranch(hash []byte) error {
+	return bc.DB.Update(func(dbtx *bolt.Tx) error {
+		headers := dbtx.Bucket([]byte(headersBucket))
+
+		// headers only point back, so find what's on top of what first
+		children := make(map[string][][]byte)
+		err := headers.ForEach(func(k, v []byte) error {
+			if string(k) == bestHeaderKey {
+				return nil
+			}
+			header, err := DeserializeHeader(v)
+			if err != nil {
+				return err
+			}
+			prev := hex.EncodeToString(header.PrevBlockHash)
+			children[prev] = append(children[prev], append([]byte{}, k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		blocks := dbtx.Bucket([]byte(blocksBucket))
+		forgotten := make(map[string]bool)
+		branch := [][]byte{hash}
+		for len(branch) > 0 {
+			h := branch[0]
+			branch = append(branch[1:], children[hex.EncodeToString(h)]...)
+			forgotten[hex.EncodeToString(h)] = true
+			if err := blocks.Delete(h); err != nil {
+				return err
+			}
+			if err := headers.Delete(h); err != nil {
+				return err
+			}
+		}
+
+		// the best header is the top of one of the chains that are
+		// left, a header nothing we still have builds on. Our latest
+		// block goes first, so it stays the best one on a tie
+		getHeader := headerGetter(headers)
+		best, err := getHeader(bc.LatestHash)
+		if err != nil {
+			return err
+		}
+		for prev, hashes := range children {
+			if forgotten[prev] {
+				continue
+			}
+			for _, h := range hashes {
+				if forgotten[hex.EncodeToString(h)] || hasChildLeft(children[hex.EncodeToString(h)], forgotten) {
+					continue
+				}
+				header, err := getHeader(h)
+				if err != nil {
+					return err
+				}
+				diff, err := workDifference(header, best, getHeader)
+				if err != nil {
+					return err
+				}
+				if diff.Sign() > 0 {
+					best = header
+				}
+			}
+		}
+		return headers.Put([]byte(bestHeaderKey), best.Hash())
+	})
+}
+
+// whether any of hashes isn't forgotten
+func hasChildLeft(hashes [][]byte, forgotten map[string]bool) bool {
+	for _, h := range hashes {
+		if !forgotten[hex.EncodeToString(h)] {
+			return true
+		}
+	}
+	return false
+}
+
+// takes the latest block off the main chain and throws it away, for
+// undoing blocks by hand. Unlike disconnectTip the block isn't kept on a
+// side chain, otherwise we'd never download it again and it would be
+// the best header forever. Peers will send it again if it's still theirs.
+// state follows like in reorganize, nil leaves it behind (the caller
+// has to rebuild it then)
+func (bc *Blockchain) RollbackTip(state ChainState) (*Block, error) {
+	tip, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(tip.PrevBlockHash) == 0 {
+		return nil, fmt.Errorf("Can't roll back the genesis block")
+	}
+	if err := bc.disconnectTip(state); err != nil {
+		return nil, err
+	}
+	if err := bc.forgetBranch(tip.Hash); err != nil {
+		return nil, err
+	}
+	return tip, nil
+}
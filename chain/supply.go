@@ -0,0 +1,102 @@
+package chain
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"blockchain/tx"
+)
+
+// how much we reward a miner for a block at the very start
+// of the chain, aka the coinbase transaction
+const InitialSubsidy = 10
+
+// the reward is cut in half every HalvingInterval blocks,
+// just like Bitcoin does every 210,000 blocks
+const HalvingInterval = 100
+
+// no matter what, there will never be more coins than this.
+// Once the rewards add up to MaxSupply miners only get fees
+const MaxSupply = 1500
+
+// the reward a miner may pay itself (on top of fees) for the block at
+// height. This only depends on the height, so every node agrees on it
+func BlockSubsidy(height int) int {
+	reward := scheduledSubsidy(height)
+	issued := issuedBefore(height)
+	if issued+reward > MaxSupply {
+		reward = MaxSupply - issued
+	}
+	if reward < 0 {
+		reward = 0
+	}
+	return reward
+}
+
+// the reward from the halving schedule alone, ignoring the cap
+func scheduledSubsidy(height int) int {
+	halvings := height / HalvingInterval
+	// shifting an int by 63 or more is always 0 anyway
+	if halvings >= 63 {
+		return 0
+	}
+	return InitialSubsidy >> halvings
+}
+
+// how many coins the schedule created in all the blocks below height.
+// Every block in a halving era pays the same, so we add up whole eras
+// instead of going one block at a time
+func issuedBefore(height int) int {
+	issued := 0
+	for start := 0; start < height; start += HalvingInterval {
+		reward := scheduledSubsidy(start)
+		if reward == 0 {
+			break
+		}
+		blocks := HalvingInterval
+		if start+blocks > height {
+			blocks = height - start
+		}
+		issued += reward * blocks
+		if issued >= MaxSupply {
+			return MaxSupply
+		}
+	}
+	return issued
+}
+
+// works out how many coins exist by going through every coinbase on the
+// chain. A coinbase also pays out the fees of its block, but those coins
+// already existed, so they have to be taken back off. The fees of a block
+// are its inputs minus its (non coinbase) outputs, so all in all the coins
+// created are just every output on the chain minus every input
+func (bc *Blockchain) IssuedSupply() (int, error) {
+	// every output seen so far, to look up what inputs were worth
+	outputs := make(map[string][]tx.TXOutput)
+	issued := 0
+
+	bestHeight := bc.GetBestHeight()
+	for height := 0; height <= bestHeight; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, transaction := range block.Transactions {
+			if !transaction.IsCoinbase() {
+				for _, vin := range transaction.Vin {
+					prevOutputs, ok := outputs[hex.EncodeToString(vin.Txid)]
+					if !ok || vin.OutputIdx < 0 || vin.OutputIdx >= len(prevOutputs) {
+						return 0, fmt.Errorf("Transaction %x spends unknown output %x:%d", transaction.ID, vin.Txid, vin.OutputIdx)
+					}
+					issued -= prevOutputs[vin.OutputIdx].Value
+				}
+			}
+			for _, vout := range transaction.Vout {
+				issued += vout.Value
+			}
+			outputs[hex.EncodeToString(transaction.ID)] = transaction.Vout
+		}
+	}
+	return issued, nil
+}
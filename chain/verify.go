@@ -0,0 +1,225 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"blockchain/tx"
+)
+
+// the reasons Verify can give for a block being invalid
+const (
+	ReasonBadPoW        = "bad proof of work"
+	ReasonBadHash       = "hash does not match the block header"
+	ReasonBadMerkleRoot = "Merkle root does not match the transactions"
+	ReasonBadLink       = "previous hash does not point at the block before it"
+	ReasonBadHeight     = "height is wrong"
+	ReasonBadVersion    = "version is wrong"
+	ReasonBadSignature  = "bad signature"
+	ReasonBadID         = "transaction ID does not match its contents"
+	ReasonDuplicateTx   = "transaction is on the chain more than once"
+	ReasonUnknownInput  = "input spends an output that never existed"
+	ReasonDoubleSpend   = "double spend"
+	ReasonOverspend     = "outputs are worth more than the inputs"
+	ReasonBadValue      = "output value is negative or too big"
+	ReasonBadCoinbase   = "bad coinbase amount"
+	ReasonLocked        = "transaction is mined before its lock time"
+	ReasonBadTipPointer = "latest block is not the top of the chain"
+)
+
+// ChainError is what Verify returns when it finds a block
+// that breaks the rules. Detail says what exactly was wrong
+type ChainError struct {
+	Height int
+	Hash   []byte
+	Reason string
+	Detail string
+}
+
+func (e *ChainError) Error() string {
+	msg := fmt.Sprintf("block %d (%x) is invalid: %s", e.Height, e.Hash, e.Reason)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// replays the whole chain starting from the genesis block. Besides the
+// proof of work we check that every block links to the one before it and
+// that its hash really is the hash of its header. Then we rebuild the UTXO
+// set in memory as we go, so we can check that every transaction's ID is
+// its hash and isn't anywhere else on the chain, that every input spends an output that exists and hasn't been
+// spent yet, that signatures are right, that no transaction spends more
+// than it has or is mined before its locks pass, and that coinbases pay at
+// most the subsidy plus the fees of the block's other transactions.
+// The blocks from the first version of the program (version 0, see
+// migrateOriginalChain) can only be checked for their links and heights,
+// their transactions just go in the UTXO set the way they are.
+// Returns the first problem found as a *ChainError, or nil
+func (bc *Blockchain) Verify() error {
+	// transaction ID (hex) -> output index -> unspent output
+	unspent := make(map[string]map[int]tx.TXOutput)
+	// every transaction seen so far, needed to check signatures,
+	// and the height of the block each one is in for relative locks
+	seen := make(map[string]tx.Transaction)
+	seenHeight := make(map[string]int)
+	// outputs that have been spent, to tell a double spend apart
+	// from an input that points at nothing at all
+	spent := make(map[string]bool)
+	// the outputs of a transaction in the block at height are
+	// available to the transactions after it
+	addOutputs := func(transaction *tx.Transaction, height int) {
+		txID := hex.EncodeToString(transaction.ID)
+		unspent[txID] = make(map[int]tx.TXOutput)
+		for idx, out := range transaction.Vout {
+			unspent[txID][idx] = out
+		}
+		seen[txID] = *transaction
+		seenHeight[txID] = height
+	}
+
+	var prev *Block
+	bestHeight := bc.GetBestHeight()
+	for height := 0; height <= bestHeight; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return &ChainError{height, nil, ReasonBadLink, err.Error()}
+		}
+		fail := func(reason, detail string, args ...interface{}) error {
+			return &ChainError{height, block.Hash, reason, fmt.Sprintf(detail, args...)}
+		}
+
+		if block.Height != height {
+			return fail(ReasonBadHeight, "block says %d", block.Height)
+		}
+		if prev == nil && len(block.PrevBlockHash) != 0 {
+			return fail(ReasonBadLink, "genesis block has a previous hash")
+		}
+		if prev != nil && !bytes.Equal(block.PrevBlockHash, prev.Hash) {
+			return fail(ReasonBadLink, "expected %x, got %x", prev.Hash, block.PrevBlockHash)
+		}
+
+		if block.Version == 0 {
+			if prev != nil && prev.Version != 0 {
+				return fail(ReasonBadVersion, "version 0 on top of version %d", prev.Version)
+			}
+			for _, transaction := range block.Transactions {
+				if !transaction.IsCoinbase() {
+					for _, vin := range transaction.Vin {
+						inID := hex.EncodeToString(vin.Txid)
+						delete(unspent[inID], vin.OutputIdx)
+						spent[fmt.Sprintf("%s:%d", inID, vin.OutputIdx)] = true
+					}
+				}
+				addOutputs(transaction, height)
+			}
+			prev = block
+			continue
+		}
+
+		if headerHash := block.HeaderHash(); !bytes.Equal(headerHash, block.Hash) {
+			return fail(ReasonBadHash, "header hashes to %x", headerHash)
+		}
+		if !bc.ValidateProofOfWork(block) {
+			return fail(ReasonBadPoW, "")
+		}
+		merkleRoot, err := block.HashTransactions()
+		if err != nil {
+			return fail(ReasonBadMerkleRoot, "%s", err)
+		}
+		if !bytes.Equal(merkleRoot, block.MerkleRoot) {
+			return fail(ReasonBadMerkleRoot, "transactions hash to %x", merkleRoot)
+		}
+
+		var medianTime int64
+		if prev != nil {
+			prevHeader := prev.Header()
+			medianTime, err = bc.MedianTimePast(&prevHeader)
+			if err != nil {
+				return fail(ReasonBadLink, "%s", err)
+			}
+		}
+
+		coinbases := 0
+		coinbaseTotal := 0
+		fees := 0
+		for _, transaction := range block.Transactions {
+			txID := hex.EncodeToString(transaction.ID)
+			if err := transaction.CheckID(); err != nil {
+				return fail(ReasonBadID, "%s", err)
+			}
+			// in this block or any before it, the second one
+			// would take the first one's place in the UTXO set
+			if _, ok := seen[txID]; ok {
+				return fail(ReasonDuplicateTx, "transaction %s is in block %d already", txID, seenHeight[txID])
+			}
+
+			outputTotal, err := transaction.OutputTotal()
+			if err != nil {
+				return fail(ReasonBadValue, "%s", err)
+			}
+
+			if !transaction.IsFinal(height, medianTime) {
+				return fail(ReasonLocked, "transaction %s is locked until %s", txID, tx.DescribeLockTime(transaction.LockTime))
+			}
+
+			if transaction.IsCoinbase() {
+				coinbases++
+				coinbaseTotal += outputTotal
+				if coinbases > 1 {
+					return fail(ReasonBadCoinbase, "block has more than one coinbase transaction")
+				}
+			} else {
+				inputTotal := 0
+				prevTXs := make(map[string]tx.Transaction)
+				for _, vin := range transaction.Vin {
+					inID := hex.EncodeToString(vin.Txid)
+					outpoint := fmt.Sprintf("%s:%d", inID, vin.OutputIdx)
+
+					out, ok := unspent[inID][vin.OutputIdx]
+					if !ok {
+						if spent[outpoint] {
+							return fail(ReasonDoubleSpend, "transaction %s spends %s again", txID, outpoint)
+						}
+						return fail(ReasonUnknownInput, "transaction %s spends %s", txID, outpoint)
+					}
+					// remove it now, so the same output twice in
+					// one transaction counts as a double spend too
+					delete(unspent[inID], vin.OutputIdx)
+					spent[outpoint] = true
+
+					if unlocksAt := seenHeight[inID] + out.RelativeLock; height < unlocksAt {
+						return fail(ReasonLocked, "transaction %s spends %s which is locked until block %d", txID, outpoint, unlocksAt)
+					}
+
+					inputTotal += out.Value
+					prevTXs[inID] = seen[inID]
+				}
+
+				if outputTotal > inputTotal {
+					return fail(ReasonOverspend, "transaction %s spends %d but its inputs are worth %d", txID, outputTotal, inputTotal)
+				}
+				fees += inputTotal - outputTotal
+
+				if err := transaction.Verify(prevTXs); err != nil {
+					return fail(ReasonBadSignature, "transaction %s: %s", txID, err)
+				}
+			}
+
+			addOutputs(transaction, height)
+		}
+
+		reward := BlockSubsidy(height)
+		if coinbaseTotal > reward+fees {
+			return fail(ReasonBadCoinbase, "coinbase pays %d but the subsidy plus fees is %d", coinbaseTotal, reward+fees)
+		}
+
+		prev = block
+	}
+
+	if prev != nil && !bytes.Equal(prev.Hash, bc.LatestHash) {
+		return &ChainError{prev.Height, bc.LatestHash, ReasonBadTipPointer, ""}
+	}
+	return nil
+}
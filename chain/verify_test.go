@@ -0,0 +1,40 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"blockchain/tx"
+	"blockchain/wallet"
+
+	"github.com/boltdb/bolt"
+)
+
+// a ChainState that takes any block, so blocks the UTXO set
+// would turn away can still get on the chain for Verify to find
+type noState struct{}
+
+func (noState) ConnectBlock(dbtx *bolt.Tx, block *Block) error    { return nil }
+func (noState) DisconnectBlock(dbtx *bolt.Tx, block *Block) error { return nil }
+
+func TestVerifyFindsTransactionInTwoBlocks(t *testing.T) {
+	bc := newTestChain(t)
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase, err := tx.NewCoinbaseTX(string(w.GetAddress()), "twice", BlockSubsidy(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := bc.AddBlock([]*tx.Transaction{coinbase}, noState{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var chainErr *ChainError
+	if err := bc.Verify(); !errors.As(err, &chainErr) || chainErr.Reason != ReasonDuplicateTx || chainErr.Height != 2 {
+		t.Fatalf("expected block 2 to have a duplicate transaction, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"blockchain/chain"
+	"blockchain/tx"
+	"blockchain/utxo"
+	"blockchain/wallet"
+)
+
+// what -data means for anchor and findanchor: the path of a file, which
+// gets its SHA-256 hash anchored since documents are bigger than an output
+// can hold, or else the data itself in hex
+func anchorData(arg string) ([]byte, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		content, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(content)
+		return hash[:], nil
+	}
+	data, err := hex.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("-data has to be a file or hex, %q is neither", arg)
+	}
+	return data, nil
+}
+
+// puts data on the chain in an output nobody can spend, from pays the fee
+func (cli *CLI) anchor(from, dataArg string, fee int, mineNow bool) error {
+	if !wallet.ValidateAddress(from) {
+		return fmt.Errorf("%w: sender %q", wallet.ErrInvalidAddress, from)
+	}
+	data, err := anchorData(dataArg)
+	if err != nil {
+		return err
+	}
+
+	blockchain, err := cli.initChain(from)
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	transaction, err := tx.NewAnchorTransaction(wallets, from, data, fee, &UTXOSet, blockchain)
+	if err != nil {
+		return err
+	}
+
+	if !mineNow {
+		mempool := utxo.Mempool{
+			Blockchain: blockchain,
+		}
+		if err := mempool.Add(transaction); err != nil {
+			return err
+		}
+		fmt.Printf("Transaction %x anchoring %x is waiting in the mempool, run mine to put it in a block\n", transaction.ID, data)
+		return nil
+	}
+
+	// same as send, the one anchoring gets the block reward
+	block, err := utxo.MineBlock(blockchain, []*tx.Transaction{transaction}, from)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Anchored %x in transaction %x, block %x (height %d)\n", data, transaction.ID, block.Hash, block.Height)
+	return nil
+}
+
+// prints the block that anchored data, and the Merkle proof that the
+// anchoring transaction is in it. With the proof and the block's header,
+// anyone can check the data was on chain without the rest of the block
+func (cli *CLI) findAnchor(dataArg string) error {
+	data, err := anchorData(dataArg)
+	if err != nil {
+		return err
+	}
+
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	transaction, block, err := blockchain.FindAnchor(data)
+	if err != nil {
+		return err
+	}
+	leaf, proof, err := block.TransactionProof(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%x was anchored by transaction %x\n", data, transaction.ID)
+	fmt.Printf("  Block:       %x\n", block.Hash)
+	fmt.Printf("  Height:      %d (%d confirmations)\n", block.Height, blockchain.GetBestHeight()-block.Height+1)
+	fmt.Printf("  Timestamp:   %v\n", time.Unix(block.Timestamp, 0))
+	fmt.Printf("  Merkle root: %x\n", block.MerkleRoot)
+	fmt.Printf("  Leaf:        %x\n", leaf)
+	fmt.Printf("  Proof:\n")
+	for _, step := range proof {
+		side := "right"
+		if step.Left {
+			side = "left "
+		}
+		fmt.Printf("    %s %x\n", side, step.Hash)
+	}
+	return nil
+}
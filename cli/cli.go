@@ -0,0 +1,1172 @@
+// the command line interface, each command is one method on CLI
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"blockchain/api"
+	"blockchain/chain"
+	"blockchain/script"
+	"blockchain/server"
+	"blockchain/tx"
+	"blockchain/utxo"
+	"blockchain/wallet"
+)
+
+// what the program exits with when a command fails, so scripts can
+// tell the common problems apart without parsing the message
+const (
+	exitError = 1
+	// 2 is taken, the flag package exits with it on bad flags
+	exitInsufficientFunds  = 3
+	exitWalletNotFound     = 4
+	exitInvalidAddress     = 5
+	exitInvalidSignature   = 6
+	exitUnknownTransaction = 7
+	exitBlockNotFound      = 8
+	exitWrongPassphrase    = 9
+	exitLocked             = 10
+)
+
+// CLI responsible for processing command line arguments
+type CLI struct {
+	bc *chain.Blockchain
+	// picks which database and wallets files to use, so several nodes
+	// can run on one machine. Read from the NODE_ID environment variable
+	nodeID string
+	// the directory those files are in, the working directory if empty.
+	// Set with -datadir or the BLOCKCHAIN_DATADIR environment variable
+	dataDir string
+	// the wallet passphrase once we've been given it, see passphrase.go
+	pass []byte
+}
+
+func (cli *CLI) printUsage() {
+	fmt.Println("Usage: blockchain [-datadir DIR] COMMAND")
+	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  newblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
+	fmt.Println("  printchain - Print all the blocks of the blockchain")
+	fmt.Println("  getblock -height HEIGHT - Print the block at HEIGHT (genesis is 0)")
+	fmt.Println("  verifychain - Replay the whole chain from genesis and report the first invalid block")
+	fmt.Println("  rollback -blocks N - Take the last N blocks off the chain, their transactions go back in the mempool")
+	fmt.Println("  supply - Show how many coins have been issued so far and the maximum supply")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT [-fee FEE] [-locktime HEIGHT|TIME] [-relativelock BLOCKS] [-coinselect STRATEGY] [-mine=false] [-dryrun] - Send AMOUNT of coins from FROM address to TO, paying FEE to the miner. With -mine=false (or a lock time that hasn't passed) the transaction waits in the mempool, -dryrun only prints what it would spend, with -relativelock TO can't spend the coins until BLOCKS blocks after they're mined. STRATEGY picks the coins: accumulate (the default), largest, bnb (exact amount, no change) or minchange")
+	fmt.Println("  sendmany -from FROM -to ADDR:AMOUNT,... | -file FILE [-fee FEE] [-coinselect STRATEGY] [-mine=false] [-dryrun] - Pay several addresses from FROM in one transaction (-dryrun only prints it), FILE is CSV (address,amount lines) or JSON ([{\"address\": ..., \"amount\": ...}])")
+	fmt.Println("  anchor -from FROM -data HEX|FILE [-fee FEE] [-mine=false] - Put data (or the SHA-256 of FILE) on the chain in an output nobody can spend, FROM pays the fee")
+	fmt.Println("  findanchor -data HEX|FILE - Find the block that anchored the data and print the Merkle proof that it's in there")
+	fmt.Println("  provetx -txid TXID - Print the header of the block TXID is in and the Merkle proof that it's in there, as JSON for light clients")
+	fmt.Println("  mine -address ADDRESS - Mine all the transactions in the mempool into one block, reward goes to ADDRESS")
+	fmt.Println("  listaddresses - list all the addresses on this network")
+	fmt.Println("  createmultisig -m M -keys KEY1,KEY2,... - Make an address that needs M of the keys (addresses in the wallet or public keys in hex) to spend from")
+	fmt.Println("  createwallet - Derives the next keypair from the wallet's mnemonic (making one the first time), returns your address")
+	fmt.Println("  restorewallet -mnemonic \"WORDS\" - Bring back the addresses of a mnemonic and show their balances")
+	fmt.Println("  migratewallet [-fee FEE] - Give old style keys compressed public keys and send their coins to the new addresses")
+	fmt.Println("  encryptwallet - Encrypt the wallets file with a passphrase")
+	fmt.Println("  changepassphrase - Change the passphrase of an encrypted wallets file")
+	fmt.Println("  startnode -port PORT [-miner ADDRESS] - Start a node listening on PORT, mining rewards go to ADDRESS")
+	fmt.Println("  serve [-http ADDR] [-token TOKEN] - Serve a JSON API for the blockchain and wallets on ADDR (default 127.0.0.1:8080), send and createwallet need TOKEN")
+	fmt.Println("  clear - Clears this node's files (blockchain.db) and (wallets.dat)")
+	fmt.Println("Set the NODE_ID environment variable to use blockchain_NODE_ID.db and wallets_NODE_ID.dat instead of blockchain.db and wallets.dat")
+	fmt.Println("The files are kept in DIR, or the BLOCKCHAIN_DATADIR environment variable, or else the working directory")
+	fmt.Println("Passphrases are asked for when needed, or read from BLOCKCHAIN_PASSPHRASE (and BLOCKCHAIN_NEW_PASSPHRASE for a new one)")
+}
+
+func (cli *CLI) validateArgLength(args []string) {
+	if len(args) < 1 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+}
+
+func (cli *CLI) Run() {
+	cli.nodeID = os.Getenv("NODE_ID")
+
+	// flags that come before the command and apply to all of them
+	global := flag.NewFlagSet("blockchain", flag.ExitOnError)
+	global.Usage = cli.printUsage
+	global.StringVar(&cli.dataDir, "datadir", os.Getenv("BLOCKCHAIN_DATADIR"), "Directory to keep the database and wallets in")
+	if err := global.Parse(os.Args[1:]); err != nil {
+		log.Panic(err)
+	}
+	args := global.Args()
+	cli.validateArgLength(args)
+
+	// define two possible commands
+	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	printChain := flag.NewFlagSet("printchain", flag.ExitOnError)
+	newBlockchain := flag.NewFlagSet("newblockchain", flag.ExitOnError)
+	getBalance := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	createWallet := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	listAddresses := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	clear := flag.NewFlagSet("clear", flag.ExitOnError)
+	startNode := flag.NewFlagSet("startnode", flag.ExitOnError)
+	getBlock := flag.NewFlagSet("getblock", flag.ExitOnError)
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	verifyChain := flag.NewFlagSet("verifychain", flag.ExitOnError)
+	supplyCmd := flag.NewFlagSet("supply", flag.ExitOnError)
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	encryptWallet := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	changePassphrase := flag.NewFlagSet("changepassphrase", flag.ExitOnError)
+	restoreWallet := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	migrateWallet := flag.NewFlagSet("migratewallet", flag.ExitOnError)
+	createMultisig := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	anchorCmd := flag.NewFlagSet("anchor", flag.ExitOnError)
+	findAnchor := flag.NewFlagSet("findanchor", flag.ExitOnError)
+	proveTx := flag.NewFlagSet("provetx", flag.ExitOnError)
+	rollbackCmd := flag.NewFlagSet("rollback", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+
+	// extra args
+	getBalanceAddress := getBalance.String("address", "", "address to get balance from")
+	newBlockchainAddress := newBlockchain.String("address", "", "The address to send genesis block reward to")
+	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	sendTo := sendCmd.String("to", "", "Destination wallet address")
+	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendFee := sendCmd.Int("fee", 0, "Fee to pay the miner of the block")
+	sendMine := sendCmd.Bool("mine", true, "Mine a block right away instead of putting the transaction in the mempool")
+	sendLockTime := sendCmd.Int64("locktime", 0, "Block height (or Unix time if it's 500000000 or more) the transaction can't be mined before")
+	sendRelativeLock := sendCmd.Int("relativelock", 0, "How many blocks after it's mined the payment can't be spent for")
+	sendCoinSelect := sendCmd.String("coinselect", "accumulate", "How to pick the coins to spend: accumulate, largest, bnb or minchange")
+	sendDryRun := sendCmd.Bool("dryrun", false, "Print the transaction without sending it")
+	mineAddress := mineCmd.String("address", "", "The address to send the block reward to")
+	startNodePort := startNode.String("port", "", "Port to listen on")
+	startNodeMiner := startNode.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	getBlockHeight := getBlock.Int("height", -1, "Height of the block to print")
+	serveAddr := serveCmd.String("http", "127.0.0.1:8080", "Address to serve the API on")
+	serveToken := serveCmd.String("token", "", "Token the endpoints that change things need, made up if not given (or set "+apiTokenEnv+")")
+	restoreMnemonic := restoreWallet.String("mnemonic", "", "The mnemonic phrase the wallet was made with")
+	migrateFee := migrateWallet.Int("fee", 0, "Fee to pay the miner for each address that gets its coins moved")
+	multisigM := createMultisig.Int("m", 0, "How many of the keys have to sign")
+	multisigKeys := createMultisig.String("keys", "", "Comma separated addresses from the wallet or public keys in hex")
+	anchorFrom := anchorCmd.String("from", "", "Address that pays the fee")
+	anchorDataArg := anchorCmd.String("data", "", "Data in hex, or a file to anchor the SHA-256 hash of")
+	anchorFee := anchorCmd.Int("fee", 0, "Fee to pay the miner of the block")
+	anchorMine := anchorCmd.Bool("mine", true, "Mine a block right away instead of putting the transaction in the mempool")
+	findAnchorData := findAnchor.String("data", "", "Data in hex, or a file whose SHA-256 hash was anchored")
+	proveTxID := proveTx.String("txid", "", "ID of the transaction to prove, in hex")
+	rollbackBlocks := rollbackCmd.Int("blocks", 0, "How many blocks to take off the chain")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address")
+	sendManyTo := sendManyCmd.String("to", "", "Comma separated ADDRESS:AMOUNT pairs")
+	sendManyFile := sendManyCmd.String("file", "", "CSV or JSON file of recipients, instead of -to")
+	sendManyFee := sendManyCmd.Int("fee", 0, "Fee to pay the miner of the block")
+	sendManyCoinSelect := sendManyCmd.String("coinselect", "accumulate", "How to pick the coins to spend: accumulate, largest, bnb or minchange")
+	sendManyMine := sendManyCmd.Bool("mine", true, "Mine a block right away instead of putting the transaction in the mempool")
+	sendManyDryRun := sendManyCmd.Bool("dryrun", false, "Print the transaction without sending it")
+
+	// call Parse depending on what the subcommand is?
+	switch args[0] {
+	case "send":
+		err := sendCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "printchain":
+		err := printChain.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "newblockchain":
+		err := newBlockchain.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getbalance":
+		err := getBalance.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createwallet":
+		err := createWallet.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listaddresses":
+		err := listAddresses.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "clear":
+		err := clear.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "startnode":
+		err := startNode.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getblock":
+		err := getBlock.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "mine":
+		err := mineCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifychain":
+		err := verifyChain.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "supply":
+		err := supplyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "serve":
+		err := serveCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWallet.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "changepassphrase":
+		err := changePassphrase.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWallet.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "migratewallet":
+		err := migrateWallet.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createmultisig":
+		err := createMultisig.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "anchor":
+		err := anchorCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "findanchor":
+		err := findAnchor.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "provetx":
+		err := proveTx.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "rollback":
+		err := rollbackCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmany":
+		err := sendManyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	// every command hands back its error here, so
+	// that all of them get reported the same way
+	var err error
+
+	if sendCmd.Parsed() {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendFee < 0 || *sendLockTime < 0 || *sendRelativeLock < 0 {
+			sendCmd.Usage()
+			os.Exit(1)
+		}
+
+		locks := tx.Locks{LockTime: *sendLockTime, RelativeLock: *sendRelativeLock}
+		err = cli.send(*sendFrom, *sendTo, *sendAmount, *sendFee, locks, *sendCoinSelect, *sendMine, *sendDryRun)
+	}
+
+	if getBalance.Parsed() {
+		if *getBalanceAddress == "" {
+			getBalance.Usage()
+			os.Exit(1)
+		}
+		err = cli.getBalance(*getBalanceAddress)
+	}
+
+	// if it was to print chain
+	if printChain.Parsed() {
+		err = cli.printChain()
+	}
+
+	if newBlockchain.Parsed() {
+		err = cli.InitBlockchain(*newBlockchainAddress)
+	}
+
+	if createWallet.Parsed() {
+		err = cli.createWallet()
+	}
+
+	if listAddresses.Parsed() {
+		err = cli.listAddresses()
+	}
+
+	if clear.Parsed() {
+		cli.clear()
+	}
+
+	if startNode.Parsed() {
+		if *startNodePort == "" {
+			startNode.Usage()
+			os.Exit(1)
+		}
+		err = cli.startNode(*startNodePort, *startNodeMiner)
+	}
+
+	if getBlock.Parsed() {
+		if *getBlockHeight < 0 {
+			getBlock.Usage()
+			os.Exit(1)
+		}
+		err = cli.getBlock(*getBlockHeight)
+	}
+
+	if mineCmd.Parsed() {
+		if *mineAddress == "" {
+			mineCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.mine(*mineAddress)
+	}
+
+	if verifyChain.Parsed() {
+		err = cli.verifyChain()
+	}
+
+	if supplyCmd.Parsed() {
+		err = cli.supply()
+	}
+
+	if serveCmd.Parsed() {
+		err = cli.serve(*serveAddr, *serveToken)
+	}
+
+	if encryptWallet.Parsed() {
+		err = cli.encryptWallet()
+	}
+
+	if changePassphrase.Parsed() {
+		err = cli.changePassphrase()
+	}
+
+	if restoreWallet.Parsed() {
+		if *restoreMnemonic == "" {
+			restoreWallet.Usage()
+			os.Exit(1)
+		}
+		err = cli.restoreWallet(*restoreMnemonic)
+	}
+
+	if migrateWallet.Parsed() {
+		if *migrateFee < 0 {
+			migrateWallet.Usage()
+			os.Exit(1)
+		}
+		err = cli.migrateWallet(*migrateFee)
+	}
+
+	if createMultisig.Parsed() {
+		if *multisigM <= 0 || *multisigKeys == "" {
+			createMultisig.Usage()
+			os.Exit(1)
+		}
+		err = cli.createMultisig(*multisigM, strings.Split(*multisigKeys, ","))
+	}
+
+	if anchorCmd.Parsed() {
+		if *anchorFrom == "" || *anchorDataArg == "" || *anchorFee < 0 {
+			anchorCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.anchor(*anchorFrom, *anchorDataArg, *anchorFee, *anchorMine)
+	}
+
+	if findAnchor.Parsed() {
+		if *findAnchorData == "" {
+			findAnchor.Usage()
+			os.Exit(1)
+		}
+		err = cli.findAnchor(*findAnchorData)
+	}
+
+	if proveTx.Parsed() {
+		if *proveTxID == "" {
+			proveTx.Usage()
+			os.Exit(1)
+		}
+		err = cli.proveTx(*proveTxID)
+	}
+
+	if rollbackCmd.Parsed() {
+		if *rollbackBlocks <= 0 {
+			rollbackCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.rollback(*rollbackBlocks)
+	}
+
+	if sendManyCmd.Parsed() {
+		// exactly one of -to and -file
+		if *sendManyFrom == "" || (*sendManyTo == "") == (*sendManyFile == "") || *sendManyFee < 0 {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		var payments []tx.Payment
+		if *sendManyTo != "" {
+			payments, err = parseRecipients(*sendManyTo)
+		} else {
+			payments, err = readRecipients(*sendManyFile)
+		}
+		if err == nil {
+			err = cli.sendMany(*sendManyFrom, payments, *sendManyFee, *sendManyCoinSelect, *sendManyMine, *sendManyDryRun)
+		}
+	}
+
+	if err != nil {
+		cli.exit(err)
+	}
+}
+
+// prints a message for someone at the terminal (not a stack trace)
+// and exits with the code that goes with the kind of error
+func (cli *CLI) exit(err error) {
+	code := exitError
+	hint := ""
+	switch {
+	case errors.Is(err, tx.ErrInsufficientFunds):
+		code = exitInsufficientFunds
+		hint = "check the balance with getbalance, pending transactions in the mempool count as spent"
+	case errors.Is(err, wallet.ErrWalletNotFound):
+		code = exitWalletNotFound
+		hint = "the keys for this address aren't in " + cli.walletPath() + ", see listaddresses"
+	case errors.Is(err, wallet.ErrInvalidAddress):
+		code = exitInvalidAddress
+		hint = "addresses come from createwallet, check it was copied correctly"
+	case errors.Is(err, tx.ErrInvalidSignature):
+		code = exitInvalidSignature
+		hint = "a transaction was not signed by the owner of the coins it spends"
+	case errors.Is(err, tx.ErrUnknownTransaction):
+		code = exitUnknownTransaction
+		hint = "a transaction spends coins that aren't on the chain (or were already spent)"
+	case errors.Is(err, chain.ErrBlockNotFound):
+		code = exitBlockNotFound
+	case errors.Is(err, wallet.ErrWrongPassphrase):
+		code = exitWrongPassphrase
+		hint = "type the passphrase the wallets were encrypted with, or set " + passphraseEnv
+	case errors.Is(err, tx.ErrLocked):
+		code = exitLocked
+		hint = "the transaction can go in a block once its lock time (or the relative lock of the coins it spends) has passed"
+	}
+
+	fmt.Println("ERROR:", err)
+	if hint != "" {
+		fmt.Println("      ", hint)
+	}
+	os.Exit(code)
+}
+
+// prints out each block in the chain
+func (cli *CLI) printChain() error {
+	if cli.bc == nil {
+		blockchain, err := chain.OpenBlockchain(cli.dbPath())
+		if err != nil {
+			return err
+		}
+		defer blockchain.DB.Close()
+		cli.bc = blockchain
+	}
+	if len(cli.bc.LatestHash) == 0 {
+		fmt.Println("The blockchain is empty, make one with newblockchain")
+		return nil
+	}
+	curIterator := cli.bc.Iterator()
+
+	for {
+		// this returns the current block that iterator points to
+		// despite the name being Next, it just moves the iterator next one
+		block, err := curIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		// validate if the block is valid once again
+		isValid := cli.bc.ValidateProofOfWork(block)
+
+		// print all our findings
+		fmt.Printf("Block %d with hash %x, Prev Hash: %x, PoW: %s\n\n", block.Height, block.Hash, block.PrevBlockHash, strconv.FormatBool(isValid))
+
+		// terminate when the previous block hash is empty
+		// meaning we are at the genesis block
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// prints out one block and the transactions inside it
+func (cli *CLI) getBlock(height int) error {
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	block, err := blockchain.GetBlockByHeight(height)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Block %x\n", block.Hash)
+	fmt.Printf("  Height:    %d\n", block.Height)
+	fmt.Printf("  Version:   %d\n", block.Version)
+	fmt.Printf("  Prev hash: %x\n", block.PrevBlockHash)
+	fmt.Printf("  Merkle:    %x\n", block.MerkleRoot)
+	fmt.Printf("  Timestamp: %v\n", time.Unix(block.Timestamp, 0))
+	fmt.Printf("  Bits:      %d\n", block.Bits)
+	fmt.Printf("  Nonce:     %d\n", block.Nonce)
+	fmt.Printf("  PoW:       %s\n", strconv.FormatBool(blockchain.ValidateProofOfWork(block)))
+	fmt.Printf("  Transactions:\n")
+	for _, transaction := range block.Transactions {
+		fmt.Printf("    %x\n", transaction.ID)
+		if transaction.LockTime != 0 {
+			fmt.Printf("      locked until %s\n", tx.DescribeLockTime(transaction.LockTime))
+		}
+		for _, vin := range transaction.Vin {
+			if transaction.IsCoinbase() {
+				fmt.Printf("      in:  coinbase\n")
+				break
+			}
+			fmt.Printf("      in:  %x:%d\n", vin.Txid, vin.OutputIdx)
+		}
+		for idx, vout := range transaction.Vout {
+			fmt.Printf("      out: %d -> %x (index %d)\n", vout.Value, vout.PublicKeyHash, idx)
+			if vout.RelativeLock != 0 {
+				fmt.Printf("           spendable %d blocks after this one\n", vout.RelativeLock)
+			}
+			if len(vout.Script) > 0 {
+				fmt.Printf("           script: %s\n", script.Disassemble(vout.Script))
+			}
+		}
+	}
+	return nil
+}
+
+// checks every block from genesis up, unlike printchain
+// which only looks at the proof of work of each block
+func (cli *CLI) verifyChain() error {
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	if err := blockchain.Verify(); err != nil {
+		return fmt.Errorf("Chain is NOT valid, %w", err)
+	}
+	fmt.Printf("Chain is valid, checked %d blocks\n", blockchain.GetBestHeight()+1)
+	return nil
+}
+
+// compares the coins that exist right now with the most that ever will
+func (cli *CLI) supply() error {
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	issued, err := blockchain.IssuedSupply()
+	if err != nil {
+		return err
+	}
+
+	nextHeight := blockchain.GetBestHeight() + 1
+	fmt.Printf("Issued supply: %d of %d (%.2f%%)\n", issued, chain.MaxSupply, float64(issued)*100/float64(chain.MaxSupply))
+	fmt.Printf("Reward for the next block (height %d): %d\n", nextHeight, chain.BlockSubsidy(nextHeight))
+	fmt.Printf("The reward halves every %d blocks\n", chain.HalvingInterval)
+	return nil
+}
+
+func (cli *CLI) InitBlockchain(address string) error {
+	if !wallet.ValidateAddress(address) {
+		return fmt.Errorf("%w: %q", wallet.ErrInvalidAddress, address)
+	}
+
+	// if blockchain already exists this does nothing basically
+	blockchain, err := cli.initChain(address)
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	// create UTXO Set
+	utxoset := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	// and initialize it
+	return utxoset.Reindex()
+}
+
+// the essence of sending is two parts:
+// 1. deducting "amount" from from's balance
+// 2. adding "amount" to to's balance
+
+// The first part is done by populating the TXInput array on the new transaction
+// to POINT to the TXOutput that we are consuming
+// (using TXInput's Txid and OutputIdx fields)
+// so that later when FindUnspentTransactions runs during something like getBalance,
+// it will recognize the previously unspent outputs as spent, and the balance
+// will effectively be "deducted" from from's account, because spent
+// outputs are skipped when counting total balance. That's the whole idea.
+
+// the second part is done by making available a bunch of new UTXOs,
+// whose 'PublicKeyHash' field is the corresponding hash of the person
+// to receive the money. And again, when getBalance runs again, these
+// new outputs are not tied to any input and hence are added to the balance
+// of the owner
+
+// if mineNow is false we don't make a block at all, the transaction
+// goes into the mempool and waits for someone to run mine. With dryRun
+// it only gets printed, so what it spends can be checked first.
+// coinSelect names one of tx.CoinSelectors, that's how the coins get picked
+func (cli *CLI) send(from, to string, amount, fee int, locks tx.Locks, coinSelect string, mineNow, dryRun bool) error {
+
+	if !wallet.ValidateAddress(from) {
+		return fmt.Errorf("%w: sender %q", wallet.ErrInvalidAddress, from)
+	}
+	if !wallet.ValidateAddress(to) {
+		return fmt.Errorf("%w: recipient %q", wallet.ErrInvalidAddress, to)
+	}
+	selector, err := tx.FindCoinSelector(coinSelect)
+	if err != nil {
+		return err
+	}
+
+	blockchain, err := cli.initChain(from)
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	// find the wallet that has the "from" address
+	// we do this because we need to use the public/private key
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+
+	// create transaction. If from is a multisig, the keys
+	// of it that are in the wallets file sign it
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	coins := tx.CoinSelection{
+		Coins:  &UTXOSet,
+		Select: selector,
+	}
+	transaction, err := tx.NewTransactionFromWallets(wallets, from, to, amount, fee, locks, coins, blockchain)
+	if err != nil {
+		return err
+	}
+	if err := printSpend(transaction, from, &UTXOSet); err != nil {
+		return err
+	}
+	if dryRun {
+		printDryRun()
+		return nil
+	}
+	mempool := utxo.Mempool{
+		Blockchain: blockchain,
+	}
+
+	// a transaction that's locked can't be mined yet,
+	// so it waits in the mempool until it can
+	lockErr := blockchain.CheckLocks(transaction)
+	if lockErr != nil && !errors.Is(lockErr, tx.ErrLocked) {
+		return lockErr
+	}
+	if !mineNow || lockErr != nil {
+		err := mempool.Add(transaction)
+		if err != nil {
+			return err
+		}
+		if lockErr != nil {
+			fmt.Printf("Transaction %x (fee %d) can't be mined yet (%s), it's waiting in the mempool until then\n", transaction.ID, fee, lockErr)
+			return nil
+		}
+		fmt.Printf("Transaction %x (fee %d) is waiting in the mempool, run mine to put it in a block\n", transaction.ID, fee)
+		return nil
+	}
+
+	// This is the "miners reward" in our network, to keep it simple, let's say
+	// the person who sends the transaction will get the reward
+	// for mining, although in a real implementation this obviously
+	// wouldn't be the case
+	_, err = utxo.MineBlock(blockchain, []*tx.Transaction{transaction}, from)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Successfully sent", amount, "from", from, "to", to)
+	return nil
+}
+
+// takes every transaction waiting in the mempool that isn't locked and
+// mines them all into one block, with a coinbase transaction paying address
+func (cli *CLI) mine(address string) error {
+	if !wallet.ValidateAddress(address) {
+		return fmt.Errorf("%w: miner %q", wallet.ErrInvalidAddress, address)
+	}
+
+	blockchain, err := cli.initChain(address)
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	mempool := utxo.Mempool{
+		Blockchain: blockchain,
+	}
+	pending, err := mempool.Transactions()
+	if err != nil {
+		return err
+	}
+	transactions, err := mempool.Ready()
+	if err != nil {
+		return err
+	}
+	if len(transactions) == 0 {
+		if len(pending) > 0 {
+			fmt.Printf("The %d transactions in the mempool are all still locked\n", len(pending))
+			return nil
+		}
+		fmt.Println("There are no transactions in the mempool to mine")
+		return nil
+	}
+
+	// the miner gets to keep all the fees
+	fees, err := blockchain.TotalFees(transactions)
+	if err != nil {
+		return err
+	}
+
+	block, err := utxo.MineBlock(blockchain, transactions, address)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Mined block %x with %d transactions from the mempool, collected %d in fees\n", block.Hash, len(transactions), fees)
+	return nil
+}
+
+func (cli *CLI) getBalance(address string) error {
+
+	ret := 0
+	pubKeyHash, err := wallet.GetPubkeyhashFromAddr(address)
+	if err != nil {
+		return err
+	}
+	blockchain, err := cli.initChain(address)
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	// create UTXO Set
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	unspentTransactionOutputs, err := UTXOSet.FindUTXO(pubKeyHash)
+	if err != nil {
+		return err
+	}
+
+	for _, output := range unspentTransactionOutputs {
+		ret += output.Value
+	}
+	fmt.Printf("The address %s has %d balance currently\n", address, ret)
+	return nil
+}
+
+func (cli *CLI) createWallet() error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+	newMnemonic := wallets.Mnemonic == ""
+	addr, err := wallets.CreateWallet()
+	if err != nil {
+		return err
+	}
+	if newMnemonic {
+		printMnemonic(wallets.Mnemonic, len(wallets.Wallets) > 1)
+	}
+	w, err := wallets.FindWallet(addr)
+	if err != nil {
+		return err
+	}
+	// others need this (not the address) to put it in a multisig
+	fmt.Printf("Its public key is %x\n", w.PublicKey)
+	fmt.Printf("Made a wallet, your address is %s", addr)
+
+	return wallets.SaveToFile()
+}
+
+// the mnemonic is only ever shown once, when it's made
+func printMnemonic(mnemonic string, hadRandomKeys bool) {
+	fmt.Println("Your wallet's mnemonic is:")
+	fmt.Println()
+	fmt.Println("    " + mnemonic)
+	fmt.Println()
+	fmt.Println("Write it down somewhere safe, restorewallet can bring back every address from it")
+	if hadRandomKeys {
+		fmt.Println("The addresses made before this one don't come from it, keep backing up the wallets file for those")
+	}
+}
+
+// derives the addresses of mnemonic again, keeps the ones that have
+// been used on the chain and shows their balances
+func (cli *CLI) restoreWallet(mnemonic string) error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	received, err := blockchain.ReceivedPubKeyHashes()
+	if err != nil {
+		return err
+	}
+	addresses, err := wallets.Restore(mnemonic, func(address string) (bool, error) {
+		pubKeyHash, err := wallet.GetPubkeyhashFromAddr(address)
+		if err != nil {
+			return false, err
+		}
+		return received[hex.EncodeToString(pubKeyHash)], nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		return err
+	}
+
+	// rebuild the UTXO set so the balances are up to date with the chain
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	if err := UTXOSet.Reindex(); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d addresses\n", len(addresses))
+	for _, address := range addresses {
+		pubKeyHash, err := wallet.GetPubkeyhashFromAddr(address)
+		if err != nil {
+			return err
+		}
+		outputs, err := UTXOSet.FindUTXO(pubKeyHash)
+		if err != nil {
+			return err
+		}
+		balance := 0
+		for _, output := range outputs {
+			balance += output.Value
+		}
+		fmt.Printf("The address %s has %d balance currently\n", address, balance)
+	}
+	return nil
+}
+
+// this just goes thru all the Wallet objects in Wallets
+// and creates addresses from the public keys
+func (cli *CLI) listAddresses() error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+	for address, _ := range wallets.Wallets {
+		if err := cli.getBalance(address); err != nil {
+			return err
+		}
+	}
+	for address := range wallets.Multisig {
+		if err := cli.getBalance(address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makes an address that needs m of keys to sign for its coins. Each key
+// is an address from the wallets file or a public key in hex, since
+// someone else's address only has the hash of their key
+func (cli *CLI) createMultisig(m int, keys []string) error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+
+	var pubKeys [][]byte
+	for _, key := range keys {
+		if w, ok := wallets.Wallets[key]; ok {
+			pubKeys = append(pubKeys, w.PublicKey)
+			continue
+		}
+		pubKey, err := hex.DecodeString(key)
+		if err != nil {
+			if wallet.ValidateAddress(key) {
+				return fmt.Errorf("%w: %s, use its public key in hex instead", wallet.ErrWalletNotFound, key)
+			}
+			return fmt.Errorf("%q is neither an address in %s nor a public key in hex", key, cli.walletPath())
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	ms, err := wallet.NewMultisigScript(m, pubKeys)
+	if err != nil {
+		return err
+	}
+	address := wallets.AddMultisig(ms)
+	fmt.Printf("Made a %d of %d multisig, its address is %s\n", ms.M, len(ms.PubKeys), address)
+	if signers := len(wallets.Signers(ms)); signers < ms.M {
+		fmt.Printf("Only %d of its keys are in %s, spending from it needs %d\n", signers, cli.walletPath(), ms.M)
+	}
+	return wallets.SaveToFile()
+}
+
+// the files of this node, in the data directory
+func (cli *CLI) dbPath() string {
+	return chain.DBFileName(cli.dataDir, cli.nodeID)
+}
+
+func (cli *CLI) walletPath() string {
+	return wallet.FilePath(cli.dataDir, cli.nodeID)
+}
+
+// chain.InitBlockchain on this node's database, telling
+// the user when there isn't one yet so a genesis block gets made
+func (cli *CLI) initChain(address string) (*chain.Blockchain, error) {
+	if _, err := os.Stat(cli.dbPath()); os.IsNotExist(err) {
+		fmt.Println("No blockchain detected, creating genesis block...")
+	}
+	return chain.InitBlockchain(address, cli.dbPath())
+}
+
+// only removes this node's files, other nodes in the same
+// data directory keep theirs
+func (cli *CLI) clear() {
+	e := os.Remove(cli.dbPath())
+	if e != nil {
+		fmt.Println(e)
+	}
+	e2 := os.Remove(cli.walletPath())
+	if e2 != nil {
+		fmt.Println(e2)
+	}
+}
+
+// runs a node until the process is killed. Each node needs its own
+// database, so if NODE_ID isn't set we name the database after the port
+func (cli *CLI) startNode(port, minerAddress string) error {
+	nodeID := cli.nodeID
+	if nodeID == "" {
+		nodeID = port
+	}
+
+	if minerAddress != "" {
+		if !wallet.ValidateAddress(minerAddress) {
+			return fmt.Errorf("%w: miner %q", wallet.ErrInvalidAddress, minerAddress)
+		}
+		fmt.Println("Mining is on. Address to receive rewards:", minerAddress)
+	}
+
+	dbPath := chain.DBFileName(cli.dataDir, nodeID)
+	fmt.Printf("Starting node localhost:%s using %s\n", port, dbPath)
+	return server.Start(dbPath, port, minerAddress)
+}
+
+// where serve gets its API token from when -token isn't given
+const apiTokenEnv = "BLOCKCHAIN_API_TOKEN"
+
+// serves the HTTP API until the process is killed. The database stays
+// open the whole time, so other commands can't use it while this runs.
+// Without a token (from -token or the environment) we make one up and
+// print it, so send and createwallet are never open to anyone who can
+// connect. The wallets' passphrase isn't asked for, each request that
+// needs it has to send it (see api.PassphraseHeader)
+func (cli *CLI) serve(addr, token string) error {
+	if token == "" {
+		token = os.Getenv(apiTokenEnv)
+	}
+	if token == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(random)
+		fmt.Printf("API token (send it as Authorization: Bearer TOKEN): %s\n", token)
+	}
+
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	fmt.Printf("Serving the API on %s using %s\n", addr, cli.dbPath())
+	return api.ListenAndServe(addr, blockchain, cli.walletPath(), token)
+}
+
+// starts encrypting a wallets file that isn't encrypted yet
+func (cli *CLI) encryptWallet() error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+	if wallets.Encrypted() {
+		return fmt.Errorf("%s is already encrypted, use changepassphrase", cli.walletPath())
+	}
+	return cli.setPassphrase(wallets)
+}
+
+func (cli *CLI) changePassphrase() error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+	if !wallets.Encrypted() {
+		return fmt.Errorf("%s isn't encrypted, use encryptwallet", cli.walletPath())
+	}
+	return cli.setPassphrase(wallets)
+}
+
+func (cli *CLI) setPassphrase(wallets *wallet.Wallets) error {
+	pass, err := cli.newPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := wallets.SetPassphrase(pass); err != nil {
+		return err
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %s with the new passphrase\n", cli.walletPath())
+	return nil
+}
+
+// wallets made before public keys were compressed have addresses that
+// hash the old encoding. The same private key with a compressed public
+// key is a new address, so we add that and move the coins over to it.
+// The old wallets stay in the file in case anything else gets sent to them
+func (cli *CLI) migrateWallet(fee int) error {
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+
+	// go through them in order, so the output doesn't jump around
+	var legacy []string
+	for address, w := range wallets.Wallets {
+		if w.IsLegacy() {
+			legacy = append(legacy, address)
+		}
+	}
+	sort.Strings(legacy)
+	if len(legacy) == 0 {
+		fmt.Println("Every wallet already has a compressed public key")
+		return nil
+	}
+
+	upgraded := make(map[string]string)
+	for _, address := range legacy {
+		newWallet := wallets.Wallets[address].Upgraded()
+		newAddress := string(newWallet.GetAddress())
+		if _, ok := wallets.Wallets[newAddress]; !ok {
+			fmt.Printf("%s is now %s\n", address, newAddress)
+			wallets.Wallets[newAddress] = newWallet
+		}
+		upgraded[address] = newAddress
+	}
+	// save the new keys before moving any coins to them
+	if err := wallets.SaveToFile(); err != nil {
+		return err
+	}
+
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+
+	var sweeps []*tx.Transaction
+	for _, address := range legacy {
+		newAddress := upgraded[address]
+		oldWallet := wallets.Wallets[address]
+		// only what can go in the next block, coins that are still
+		// locked or already being spent in the mempool have to wait
+		coins, err := UTXOSet.SpendableCoins(wallet.HashPubKey(oldWallet.PublicKey))
+		if err != nil {
+			return err
+		}
+		balance := 0
+		for _, coin := range coins {
+			balance += coin.Value
+		}
+		if balance == 0 {
+			continue
+		}
+		if balance <= fee {
+			fmt.Printf("Not moving the %d coins of %s, that isn't more than the fee\n", balance, address)
+			continue
+		}
+
+		transaction, err := tx.NewGeneralTransaction(oldWallet, newAddress, balance-fee, fee, tx.Locks{}, &UTXOSet, blockchain)
+		if err != nil {
+			return err
+		}
+		sweeps = append(sweeps, transaction)
+		fmt.Printf("Moving %d coins from %s to %s (fee %d)\n", balance-fee, address, newAddress, fee)
+	}
+	if len(sweeps) == 0 {
+		fmt.Println("No coins left to move")
+		return nil
+	}
+
+	// like send, whoever moves the coins gets the block reward
+	_, err = utxo.MineBlock(blockchain, sweeps, upgraded[legacy[0]])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved the coins of %d addresses\n", len(sweeps))
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// where the passphrases can come from instead of being typed in,
+// for scripts and nodes running without a terminal
+const passphraseEnv = "BLOCKCHAIN_PASSPHRASE"
+const newPassphraseEnv = "BLOCKCHAIN_NEW_PASSPHRASE"
+
+// stdin when it's a pipe instead of a terminal. One reader for the whole
+// run, so asking twice doesn't lose what the first read buffered
+var stdin = bufio.NewReader(os.Stdin)
+
+// the passphrase of the wallets file. This is a wallet.PassphraseFunc,
+// so it only gets asked for when the file is actually encrypted, and
+// only once per run
+func (cli *CLI) passphrase() ([]byte, error) {
+	if cli.pass != nil {
+		return cli.pass, nil
+	}
+	pass, ok := os.LookupEnv(passphraseEnv)
+	if ok {
+		cli.pass = []byte(pass)
+		return cli.pass, nil
+	}
+	p, err := readPassphrase("Wallet passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	cli.pass = p
+	return p, nil
+}
+
+// a passphrase to encrypt the wallets with from now on. Typed in
+// twice so a typo doesn't lock someone out of their coins
+func (cli *CLI) newPassphrase() ([]byte, error) {
+	pass, ok := os.LookupEnv(newPassphraseEnv)
+	if ok {
+		return []byte(pass), nil
+	}
+	p, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	again, err := readPassphrase("Repeat the new passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p, again) {
+		return nil, errors.New("the passphrases don't match")
+	}
+	return p, nil
+}
+
+// reads a line without showing it on the terminal. If stdin isn't a
+// terminal the line is just read, so a passphrase can be piped in
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		p, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return p, err
+	}
+
+	line, err := stdin.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("reading the passphrase: %w", err)
+	}
+	fmt.Fprintln(os.Stderr)
+	return bytes.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"blockchain/chain"
+)
+
+// what provetx prints, everything a light client needs to check a
+// transaction is in a block. Hashes are hex like everywhere else
+type txProof struct {
+	TxID string `json:"txid"`
+	// sha256 of the transaction's ID followed by its HashBytes
+	Leaf   string      `json:"leaf"`
+	Header proofHeader `json:"header"`
+	Proof  []proofStep `json:"proof"`
+}
+
+// the block's header. Hashing timestamp, merkleRoot, prevHash, bits and
+// nonce (the numbers as 8 byte big endian, and the version in front of
+// them once it's over 1) gives hash, which needs bits leading zeros, so
+// the client can check the header was mined too
+type proofHeader struct {
+	Hash       string `json:"hash"`
+	Version    int    `json:"version"`
+	PrevHash   string `json:"prevHash"`
+	MerkleRoot string `json:"merkleRoot"`
+	Timestamp  int64  `json:"timestamp"`
+	Bits       int    `json:"bits"`
+	Nonce      int    `json:"nonce"`
+	Height     int    `json:"height"`
+}
+
+// see chain.MerkleProofStep, bottom of the tree first
+type proofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// prints the Merkle proof that the transaction is in its block
+// along with the block's header, as JSON
+func (cli *CLI) proveTx(txid string) error {
+	id, err := hex.DecodeString(txid)
+	if err != nil {
+		return fmt.Errorf("-txid has to be hex: %v", err)
+	}
+
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	_, block, err := blockchain.FindTransactionBlock(id)
+	if err != nil {
+		return err
+	}
+	leaf, proof, err := block.TransactionProof(id)
+	if err != nil {
+		return err
+	}
+	root := block.MerkleRoot
+	// can't happen unless the tree and the proof disagree,
+	// but then nobody should get handed the proof
+	if !chain.VerifyMerkleProof(root, leaf, proof) {
+		return fmt.Errorf("the proof for %x doesn't lead to the Merkle root of block %x", id, block.Hash)
+	}
+
+	out := txProof{
+		TxID: hex.EncodeToString(id),
+		Leaf: hex.EncodeToString(leaf),
+		Header: proofHeader{
+			Hash:       hex.EncodeToString(block.Hash),
+			Version:    block.Version,
+			PrevHash:   hex.EncodeToString(block.PrevBlockHash),
+			MerkleRoot: hex.EncodeToString(root),
+			Timestamp:  block.Timestamp,
+			Bits:       block.Bits,
+			Nonce:      block.Nonce,
+			Height:     block.Height,
+		},
+		Proof: []proofStep{},
+	}
+	for _, step := range proof {
+		out.Proof = append(out.Proof, proofStep{hex.EncodeToString(step.Hash), step.Left})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
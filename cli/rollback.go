@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"blockchain/chain"
+	"blockchain/utxo"
+)
+
+// takes the last n blocks off the chain. The UTXO set goes back with
+// them (using its undo records) and their transactions go back in the
+// mempool, so whatever's still valid can be mined again. Blocks from
+// before the undo records come off without it, and the set gets rebuilt
+// from the chain at the end
+func (cli *CLI) rollback(n int) error {
+	blockchain, err := chain.OpenBlockchain(cli.dbPath())
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	if n > blockchain.GetBestHeight() {
+		return fmt.Errorf("The chain only has %d blocks after the genesis block, can't roll back %d", blockchain.GetBestHeight(), n)
+	}
+
+	// newest first, like a reorganization that doesn't connect anything
+	update := &chain.ChainUpdate{}
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	reindex := false
+	for i := 0; i < n; i++ {
+		var block *chain.Block
+		if !reindex {
+			block, err = blockchain.RollbackTip(&UTXOSet)
+			reindex = errors.Is(err, utxo.ErrNoUndoData)
+		}
+		if reindex {
+			block, err = blockchain.RollbackTip(nil)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back block %x (height %d)\n", block.Hash, block.Height)
+		update.Disconnected = append(update.Disconnected, block)
+	}
+	if reindex {
+		if err := UTXOSet.Reindex(); err != nil {
+			return err
+		}
+	}
+	if err := utxo.ApplyChainUpdate(blockchain, update); err != nil {
+		return err
+	}
+
+	fmt.Printf("The chain is back at block %x (height %d)\n", blockchain.LatestHash, blockchain.GetBestHeight())
+	return nil
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"blockchain/tx"
+	"blockchain/utxo"
+	"blockchain/wallet"
+)
+
+// one recipient in a sendmany JSON file
+type recipientJSON struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// the recipients of sendmany -to, like ADDR1:5,ADDR2:7
+func parseRecipients(list string) ([]tx.Payment, error) {
+	var payments []tx.Payment
+	for _, entry := range strings.Split(list, ",") {
+		address, amount, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("-to has to be ADDRESS:AMOUNT pairs, %q isn't one", entry)
+		}
+		payment, err := newRecipient(address, amount)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+// the recipients in a file, either JSON (a list of {"address", "amount"})
+// or CSV with an address and an amount on each line. A CSV header line
+// is fine, it gets skipped since its amount isn't a number
+func readRecipients(path string) ([]tx.Payment, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []tx.Payment
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "[") {
+		var recipients []recipientJSON
+		if err := json.Unmarshal(content, &recipients); err != nil {
+			return nil, fmt.Errorf("Reading %s: %w", path, err)
+		}
+		for _, r := range recipients {
+			if !wallet.ValidateAddress(r.Address) {
+				return nil, fmt.Errorf("%w: recipient %q", wallet.ErrInvalidAddress, r.Address)
+			}
+			payments = append(payments, tx.Payment{To: r.Address, Amount: r.Amount})
+		}
+		return payments, nil
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(content)))
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Reading %s: %w", path, err)
+	}
+	for i, record := range records {
+		if _, err := strconv.Atoi(record[1]); err != nil && i == 0 {
+			continue
+		}
+		payment, err := newRecipient(record[0], record[1])
+		if err != nil {
+			return nil, fmt.Errorf("Line %d of %s: %w", i+1, path, err)
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+func newRecipient(address, amount string) (tx.Payment, error) {
+	address = strings.TrimSpace(address)
+	if !wallet.ValidateAddress(address) {
+		return tx.Payment{}, fmt.Errorf("%w: recipient %q", wallet.ErrInvalidAddress, address)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(amount))
+	if err != nil {
+		return tx.Payment{}, fmt.Errorf("the amount for %s has to be a whole number, not %q", address, amount)
+	}
+	return tx.Payment{To: address, Amount: value}, nil
+}
+
+// pays everyone in payments from one transaction, see tx.NewBatchTransaction.
+// The rest works like send
+func (cli *CLI) sendMany(from string, payments []tx.Payment, fee int, coinSelect string, mineNow, dryRun bool) error {
+	if !wallet.ValidateAddress(from) {
+		return fmt.Errorf("%w: sender %q", wallet.ErrInvalidAddress, from)
+	}
+	selector, err := tx.FindCoinSelector(coinSelect)
+	if err != nil {
+		return err
+	}
+
+	blockchain, err := cli.initChain(from)
+	if err != nil {
+		return err
+	}
+	defer blockchain.DB.Close()
+
+	wallets, err := wallet.NewWallets(cli.walletPath(), cli.passphrase)
+	if err != nil {
+		return err
+	}
+	UTXOSet := utxo.UTXOSet{
+		Blockchain: blockchain,
+	}
+	coins := tx.CoinSelection{
+		Coins:  &UTXOSet,
+		Select: selector,
+	}
+	transaction, err := tx.NewBatchTransaction(wallets, from, payments, fee, coins, blockchain)
+	if err != nil {
+		return err
+	}
+	if err := printSpend(transaction, from, &UTXOSet); err != nil {
+		return err
+	}
+	if dryRun {
+		printDryRun()
+		return nil
+	}
+
+	total := 0
+	for _, payment := range payments {
+		total += payment.Amount
+	}
+
+	if !mineNow {
+		mempool := utxo.Mempool{
+			Blockchain: blockchain,
+		}
+		if err := mempool.Add(transaction); err != nil {
+			return err
+		}
+		fmt.Printf("Transaction %x paying %d recipients (fee %d) is waiting in the mempool, run mine to put it in a block\n", transaction.ID, len(payments), fee)
+		return nil
+	}
+
+	// same as send, the sender gets the block reward
+	if _, err := utxo.MineBlock(blockchain, []*tx.Transaction{transaction}, from); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully sent %d from %s to %d recipients\n", total, from, len(payments))
+	return nil
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+
+	"blockchain/tx"
+	"blockchain/utxo"
+)
+
+// prints what a transaction from "from" spends and where the money goes,
+// so it can be checked before it goes in the mempool or a block
+func printSpend(transaction *tx.Transaction, from string, utxoset *utxo.UTXOSet) error {
+	inputTotal := 0
+	fmt.Printf("Transaction %x\n", transaction.ID)
+	for _, vin := range transaction.Vin {
+		output, ok, err := utxoset.FindOutput(vin.Txid, vin.OutputIdx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: input %x:%d is not an unspent output", tx.ErrUnknownTransaction, vin.Txid, vin.OutputIdx)
+		}
+		fmt.Printf("  in   %x:%d  %d\n", vin.Txid, vin.OutputIdx, output.Value)
+		inputTotal += output.Value
+	}
+
+	outputTotal := 0
+	for _, vout := range transaction.Vout {
+		outputTotal += vout.Value
+		switch address := vout.Address(); {
+		case vout.IsUnspendable():
+			data, _ := vout.Data()
+			fmt.Printf("  out  data %x\n", data)
+		case address == from:
+			fmt.Printf("  out  %s  %d (change)\n", address, vout.Value)
+		default:
+			fmt.Printf("  out  %s  %d\n", address, vout.Value)
+		}
+	}
+	fmt.Printf("  fee  %d\n", inputTotal-outputTotal)
+	return nil
+}
+
+// what send and sendmany say instead of sending with -dryrun
+func printDryRun() {
+	fmt.Println("Dry run, nothing was sent. Run it again without -dryrun to send it")
+}
@@ -3,9 +3,14 @@ module blockchain
 go 1.18
 
 require (
-	github.com/boltdb/bolt v1.3.1 // indirect
-	github.com/btcsuite/btcutil v1.0.2 // indirect
+	github.com/boltdb/bolt v1.3.1
+	github.com/btcsuite/btcutil v1.0.2
+	github.com/tyler-smith/go-bip39 v1.1.0
+	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa
+	golang.org/x/term v0.0.0-20220722155259-a9ba230a4035
+)
+
+require (
 	github.com/itchyny/base58-go v0.2.0 // indirect
-	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
 	golang.org/x/sys v0.0.0-20220730100132-1609e554cd39 // indirect
 )
@@ -1,13 +1,13 @@
 package main
 
-// we want 24 zero bits or more to accept a hash as OK
-const targetBits = 16
+import "blockchain/cli"
 
 func main() {
 
-	// startup a cli instance with this blockchain we made last line
-	cli := CLI{}
+	// startup a cli instance, it opens the blockchain
+	// itself depending on which command it gets
+	c := cli.CLI{}
 
 	// parse user input
-	cli.Run()
+	c.Run()
 }
@@ -0,0 +1,157 @@
+// proof of work is the puzzle miners solve: find a nonce that makes the
+// hash of a block header small enough. This package only knows about
+// header bytes, so it can be used without the chain package
+package pow
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// the largest value of nonce we can try, 2^63
+const maxNonce int64 = math.MaxInt64
+
+// the difficulty can change by at most this many bits at a time,
+// and always has to stay between MinTargetBits and MaxTargetBits
+const MaxBitsAdjustment = 2
+const MinTargetBits = 8
+const MaxTargetBits = 32
+
+// a header asks for a difficulty outside MinTargetBits and MaxTargetBits.
+// Anything over 256 bits can't even be turned into a target
+var ErrInvalidBits = errors.New("difficulty out of range")
+
+// whether bits is a difficulty a header is allowed to have
+func CheckBits(bits int) error {
+	if bits < MinTargetBits || bits > MaxTargetBits {
+		return fmt.Errorf("%w: %d bits, it has to be between %d and %d", ErrInvalidBits, bits, MinTargetBits, MaxTargetBits)
+	}
+	return nil
+}
+
+// anything that can be mined. chain.Block is one of these
+type Header interface {
+	// the header as bytes, with nonce being the miner's guess
+	HeaderBytes(nonce int) []byte
+	// the difficulty, how many leading zero bits the hash needs
+	TargetBits() int
+}
+
+// the target field helps us decide whether or not a block's hash
+// is valid. If the hash <= target, then its valid, else, try again
+// this is because we're looking for a certain # of leading zeros
+type ProofOfWork struct {
+	header Header
+	target *big.Int
+}
+
+// create a new Proof of Work for a specific block header. The header's
+// difficulty gets checked first, since it comes from whoever sent it
+func NewProofOfWork(h Header) (*ProofOfWork, error) {
+	if err := CheckBits(h.TargetBits()); err != nil {
+		return nil, err
+	}
+
+	// use the math/big package to deal with large numbers
+	// this sets target = 1 << (256-bits), where bits is
+	// the difficulty stored on the block.
+	// we are doing 256 because we'll use SHA256
+	// which has 256 bit output
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-h.TargetBits()))
+
+	return &ProofOfWork{
+		h,
+		target,
+	}, nil
+}
+
+// core loop
+func (pow *ProofOfWork) Run() (int, []byte) {
+	var hashInt big.Int
+	var hash [32]byte
+	nonce := 0
+
+	// mine for the right nonce
+	for int64(nonce) < maxNonce {
+		// get the bytes for the hash
+		hashbytes := pow.header.HeaderBytes(nonce)
+
+		// perform the hash
+		hash = sha256.Sum256(hashbytes)
+
+		// send hash to big.Int form so we can compare
+		hashInt.SetBytes(hash[:])
+
+		// if hash as integer is less than target
+		// we've found a working nonce
+		if hashInt.Cmp(pow.target) == -1 {
+			break
+		} else {
+			// else keep trying (mining)
+			nonce++
+		}
+	}
+	return nonce, hash[:]
+}
+
+// the hash of the header with a certain nonce. For a mined block
+// this should be exactly what is stored in the block's Hash field
+func (pow *ProofOfWork) Hash(nonce int) []byte {
+	return Hash(pow.header, nonce)
+}
+
+// same as ProofOfWork.Hash, except the difficulty doesn't matter
+// so any header can be hashed, valid or not
+func Hash(h Header, nonce int) []byte {
+	hash := sha256.Sum256(h.HeaderBytes(nonce))
+	return hash[:]
+}
+
+// check if the header with said nonce evaluates to
+// something smaller than the target. This more or less does same
+// calculation as Run except this time we are checking the nonce
+// instead of searching for a valid one.
+// Whether the header asks for the right difficulty is up to the chain
+func (pow *ProofOfWork) Validate(nonce int) bool {
+	var hashInt big.Int
+
+	hashInt.SetBytes(pow.Hash(nonce))
+
+	if hashInt.Cmp(pow.target) == -1 {
+		return true
+	} else {
+		return false
+	}
+}
+
+// every extra bit halves the target, which means twice as much work.
+// So we move by one bit for each time the window was 2x too fast or
+// too slow, at most MaxBitsAdjustment bits
+func AdjustBits(bits int, actual, expected int64) int {
+	if actual < 1 {
+		actual = 1
+	}
+
+	change := 0
+	for actual*2 <= expected && change < MaxBitsAdjustment {
+		actual *= 2
+		change++
+	}
+	for actual >= expected*2 && change > -MaxBitsAdjustment {
+		actual /= 2
+		change--
+	}
+
+	bits += change
+	if bits < MinTargetBits {
+		bits = MinTargetBits
+	}
+	if bits > MaxTargetBits {
+		bits = MaxTargetBits
+	}
+	return bits
+}
@@ -0,0 +1,262 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"blockchain/wallet"
+)
+
+// keeps a bad script from using up all our memory
+const maxStackSize = 1000
+const MaxScriptSize = 10000
+
+var ErrScriptFailed = errors.New("script failed")
+
+type stack [][]byte
+
+func (s *stack) push(data []byte) error {
+	if len(*s) >= maxStackSize {
+		return errors.New("stack is too big")
+	}
+	*s = append(*s, data)
+	return nil
+}
+
+func (s *stack) pop() ([]byte, error) {
+	if len(*s) == 0 {
+		return nil, errors.New("stack is empty")
+	}
+	top := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return top, nil
+}
+
+func (s *stack) popInt() (int64, error) {
+	data, err := s.pop()
+	if err != nil {
+		return 0, err
+	}
+	return asInt(data)
+}
+
+// numbers on the stack are big endian and never negative, see PushInt
+func asInt(data []byte) (int64, error) {
+	if len(data) > 8 || (len(data) == 8 && data[0]&0x80 != 0) {
+		return 0, fmt.Errorf("number %x is too big", data)
+	}
+	var n int64
+	for _, b := range data {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// anything but an empty item or all zero bytes
+func asBool(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func boolBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+// runs the spender's script and then the locking script on the same stack.
+// The unlocking script can only push data, otherwise it could do whatever
+// it wanted to the stack before the lock gets to look at it. nil means
+// the coins can be spent, otherwise the error says why not
+func Verify(unlock, lock []byte, checker Checker) error {
+	if len(unlock) > MaxScriptSize || len(lock) > MaxScriptSize {
+		return fmt.Errorf("%w: script is too big", ErrScriptFailed)
+	}
+
+	unlockInstructions, err := parse(unlock)
+	if err != nil {
+		return fmt.Errorf("%w: unlocking script: %v", ErrScriptFailed, err)
+	}
+	for _, ins := range unlockInstructions {
+		if !ins.push {
+			return fmt.Errorf("%w: unlocking script can only push data", ErrScriptFailed)
+		}
+	}
+	lockInstructions, err := parse(lock)
+	if err != nil {
+		return fmt.Errorf("%w: locking script: %v", ErrScriptFailed, err)
+	}
+
+	var s stack
+	for _, instructions := range [][]instruction{unlockInstructions, lockInstructions} {
+		if err := execute(&s, instructions, checker); err != nil {
+			return fmt.Errorf("%w: %v", ErrScriptFailed, err)
+		}
+	}
+
+	top, err := s.pop()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrScriptFailed, err)
+	}
+	if !asBool(top) {
+		return fmt.Errorf("%w: script finished with false on the stack", ErrScriptFailed)
+	}
+	// signatures aren't covered by the hash that gets signed, so if extra
+	// items were allowed anyone could add some and change the txid
+	if len(s) != 0 {
+		return fmt.Errorf("%w: script left %d extra items on the stack", ErrScriptFailed, len(s))
+	}
+	return nil
+}
+
+func execute(s *stack, instructions []instruction, checker Checker) error {
+	for _, ins := range instructions {
+		if ins.push {
+			if len(ins.data) > MaxPushSize {
+				return fmt.Errorf("push of %d bytes is too big", len(ins.data))
+			}
+			if err := s.push(ins.data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var err error
+		switch ins.op {
+		case OP_RETURN:
+			return errors.New("output can't be spent (RETURN)")
+		case OP_DUP:
+			err = opDup(s)
+		case OP_HASH160:
+			err = opHash160(s)
+		case OP_EQUALVERIFY:
+			err = opEqualVerify(s)
+		case OP_CHECKSIG:
+			err = opCheckSig(s, checker)
+		case OP_CHECKMULTISIG:
+			err = opCheckMultisig(s, checker)
+		case OP_CHECKLOCKTIMEVERIFY:
+			err = opCheckLockTimeVerify(s, checker)
+		default:
+			err = fmt.Errorf("unknown opcode 0x%02x", ins.op)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", opNames[ins.op], err)
+		}
+	}
+	return nil
+}
+
+func opDup(s *stack) error {
+	top, err := s.pop()
+	if err != nil {
+		return err
+	}
+	if err := s.push(top); err != nil {
+		return err
+	}
+	return s.push(top)
+}
+
+// same hash as addresses use, sha256 and then ripemd160
+func opHash160(s *stack) error {
+	top, err := s.pop()
+	if err != nil {
+		return err
+	}
+	return s.push(wallet.HashPubKey(top))
+}
+
+func opEqualVerify(s *stack) error {
+	a, err := s.pop()
+	if err != nil {
+		return err
+	}
+	b, err := s.pop()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) {
+		return errors.New("items aren't equal")
+	}
+	return nil
+}
+
+// <signature> <public key> CHECKSIG
+func opCheckSig(s *stack, checker Checker) error {
+	pubKey, err := s.pop()
+	if err != nil {
+		return err
+	}
+	signature, err := s.pop()
+	if err != nil {
+		return err
+	}
+	return s.push(boolBytes(checker.CheckSig(signature, pubKey)))
+}
+
+// <signature 1> ... <signature M> M <key 1> ... <key N> N CHECKMULTISIG.
+// The signatures have to be in the same order as their keys, so we only
+// ever walk forward through the keys. Unlike Bitcoin there's no extra
+// item popped off the end
+func opCheckMultisig(s *stack, checker Checker) error {
+	n, err := s.popInt()
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > wallet.MaxMultisigKeys {
+		return fmt.Errorf("can't have %d keys", n)
+	}
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = s.pop(); err != nil {
+			return err
+		}
+	}
+
+	m, err := s.popInt()
+	if err != nil {
+		return err
+	}
+	if m < 1 || m > n {
+		return fmt.Errorf("can't need %d of %d signatures", m, n)
+	}
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if signatures[i], err = s.pop(); err != nil {
+			return err
+		}
+	}
+
+	k := 0
+	for _, signature := range signatures {
+		for k < len(pubKeys) && !checker.CheckSig(signature, pubKeys[k]) {
+			k++
+		}
+		if k == len(pubKeys) {
+			return s.push(boolBytes(false))
+		}
+		k++
+	}
+	return s.push(boolBytes(true))
+}
+
+// <lock time> CHECKLOCKTIMEVERIFY fails unless the spending transaction
+// is locked until at least then. The lock time gets popped, we don't
+// have a DROP to get rid of it afterwards
+func opCheckLockTimeVerify(s *stack, checker Checker) error {
+	lockTime, err := s.popInt()
+	if err != nil {
+		return err
+	}
+	if !checker.CheckLockTime(lockTime) {
+		return fmt.Errorf("transaction isn't locked until %d", lockTime)
+	}
+	return nil
+}
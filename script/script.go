@@ -0,0 +1,221 @@
+// a small stack language like Bitcoin Script. An output is locked with a
+// script, and whoever spends it provides data (signatures and keys) that
+// gets pushed on the stack before the locking script runs. If the stack
+// ends up with something true on top, the coins can be spent.
+// Checking signatures is left to a Checker, so this package doesn't
+// need to know what a transaction looks like
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// the opcodes we know about. The values are the same as Bitcoin's so
+// scripts look familiar in hex. 0x01 to 0x4b push that many bytes
+const (
+	OP_0                   byte = 0x00
+	OP_PUSHDATA1           byte = 0x4c
+	OP_PUSHDATA2           byte = 0x4d
+	OP_1                   byte = 0x51
+	OP_16                  byte = 0x60
+	OP_RETURN              byte = 0x6a
+	OP_DUP                 byte = 0x76
+	OP_EQUALVERIFY         byte = 0x88
+	OP_HASH160             byte = 0xa9
+	OP_CHECKSIG            byte = 0xac
+	OP_CHECKMULTISIG       byte = 0xae
+	OP_CHECKLOCKTIMEVERIFY byte = 0xb1
+)
+
+var opNames = map[byte]string{
+	OP_RETURN:              "RETURN",
+	OP_DUP:                 "DUP",
+	OP_EQUALVERIFY:         "EQUALVERIFY",
+	OP_HASH160:             "HASH160",
+	OP_CHECKSIG:            "CHECKSIG",
+	OP_CHECKMULTISIG:       "CHECKMULTISIG",
+	OP_CHECKLOCKTIMEVERIFY: "CHECKLOCKTIMEVERIFY",
+}
+
+// the biggest single push, and the biggest pushes that fit
+// in the opcode byte itself
+const MaxPushSize = 520
+const maxDirectPush = 0x4b
+
+// how a script gets signatures and lock times checked, the tx
+// package has one of these for each input it verifies
+type Checker interface {
+	// whether signature is a valid signature by pubKey of whatever
+	// the input commits to
+	CheckSig(signature, pubKey []byte) bool
+	// whether the spending transaction can't be mined before lockTime
+	CheckLockTime(lockTime int64) bool
+}
+
+// the bytes of a script that pushes data onto the stack
+func Push(data []byte) []byte {
+	switch {
+	case len(data) == 0:
+		return []byte{OP_0}
+	case len(data) <= maxDirectPush:
+		return append([]byte{byte(len(data))}, data...)
+	case len(data) <= 0xff:
+		return append([]byte{OP_PUSHDATA1, byte(len(data))}, data...)
+	default:
+		// anything over MaxPushSize makes the script fail anyway, but
+		// that's for Verify to say
+		length := make([]byte, 2)
+		binary.LittleEndian.PutUint16(length, uint16(len(data)))
+		return append(append([]byte{OP_PUSHDATA2}, length...), data...)
+	}
+}
+
+// pushes a number. 0 to 16 have their own opcodes, the rest are
+// pushed as big endian bytes without leading zeros
+func PushInt(n int64) []byte {
+	if n == 0 {
+		return []byte{OP_0}
+	}
+	if n > 0 && n <= 16 {
+		return []byte{OP_1 + byte(n-1)}
+	}
+	var b []byte
+	for v := uint64(n); v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return Push(b)
+}
+
+// the standard script: the spender pushes a signature and their public
+// key, and the key has to hash to pubKeyHash and the signature match it.
+// Outputs without a script of their own are locked with this
+func PayToPubKeyHash(pubKeyHash []byte) []byte {
+	var s bytes.Buffer
+	s.Write([]byte{OP_DUP, OP_HASH160})
+	s.Write(Push(pubKeyHash))
+	s.Write([]byte{OP_EQUALVERIFY, OP_CHECKSIG})
+	return s.Bytes()
+}
+
+// M <key 1> ... <key N> N CHECKMULTISIG, the spender pushes M signatures
+func Multisig(m int, pubKeys [][]byte) []byte {
+	var s bytes.Buffer
+	s.Write(PushInt(int64(m)))
+	for _, pubKey := range pubKeys {
+		s.Write(Push(pubKey))
+	}
+	s.Write(PushInt(int64(len(pubKeys))))
+	s.WriteByte(OP_CHECKMULTISIG)
+	return s.Bytes()
+}
+
+// the most data a RETURN output can carry, same as Bitcoin's default.
+// Enough for a hash and a bit, anything more should go somewhere else
+// with its hash put on chain
+const MaxNullDataSize = 80
+
+// RETURN <data>, an output nobody can ever spend that just carries data.
+// Bitcoin calls these null data outputs
+func NullData(data []byte) []byte {
+	return append([]byte{OP_RETURN}, Push(data)...)
+}
+
+// the data a NullData script carries, false if s isn't one
+func NullDataPayload(s []byte) ([]byte, bool) {
+	if len(s) == 0 || s[0] != OP_RETURN {
+		return nil, false
+	}
+	instructions, err := parse(s[1:])
+	if err != nil || len(instructions) > 1 {
+		return nil, false
+	}
+	if len(instructions) == 0 {
+		return []byte{}, true
+	}
+	if !instructions[0].push {
+		return nil, false
+	}
+	return instructions[0].data, true
+}
+
+// one step of a script, either an opcode or some data being pushed
+type instruction struct {
+	op   byte
+	data []byte
+	push bool
+}
+
+// splits a script into its instructions
+func parse(s []byte) ([]instruction, error) {
+	var instructions []instruction
+	for i := 0; i < len(s); {
+		op := s[i]
+		i++
+
+		var n int
+		switch {
+		case op == OP_0:
+			instructions = append(instructions, instruction{op, []byte{}, true})
+			continue
+		case op <= maxDirectPush:
+			n = int(op)
+		case op == OP_PUSHDATA1:
+			if i >= len(s) {
+				return nil, fmt.Errorf("PUSHDATA1 at the end of the script")
+			}
+			n = int(s[i])
+			i++
+		case op == OP_PUSHDATA2:
+			if i+2 > len(s) {
+				return nil, fmt.Errorf("PUSHDATA2 at the end of the script")
+			}
+			n = int(binary.LittleEndian.Uint16(s[i:]))
+			i += 2
+		case op >= OP_1 && op <= OP_16:
+			instructions = append(instructions, instruction{op, []byte{op - OP_1 + 1}, true})
+			continue
+		default:
+			if _, ok := opNames[op]; !ok {
+				return nil, fmt.Errorf("unknown opcode 0x%02x", op)
+			}
+			instructions = append(instructions, instruction{op: op})
+			continue
+		}
+
+		if i+n > len(s) {
+			return nil, fmt.Errorf("push of %d bytes goes past the end of the script", n)
+		}
+		instructions = append(instructions, instruction{op, s[i : i+n], true})
+		i += n
+	}
+	return instructions, nil
+}
+
+// the script written out, like "DUP HASH160 <hex> EQUALVERIFY CHECKSIG".
+// Scripts that don't parse show as hex
+func Disassemble(s []byte) string {
+	instructions, err := parse(s)
+	if err != nil {
+		return fmt.Sprintf("[invalid script %x]", s)
+	}
+	var parts []string
+	for _, ins := range instructions {
+		switch {
+		case ins.push && ins.op == OP_0:
+			parts = append(parts, "0")
+		case ins.push && ins.op >= OP_1 && ins.op <= OP_16:
+			parts = append(parts, fmt.Sprint(ins.op-OP_1+1))
+		case ins.push:
+			parts = append(parts, fmt.Sprintf("%x", ins.data))
+		default:
+			parts = append(parts, opNames[ins.op])
+		}
+	}
+	if len(parts) == 0 {
+		return "[empty script]"
+	}
+	return strings.Join(parts, " ")
+}
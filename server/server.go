@@ -0,0 +1,600 @@
+// the peer to peer part: nodes tell each other about blocks and
+// transactions over TCP, and mining nodes mine what they hear about
+package server
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"blockchain/chain"
+	"blockchain/tx"
+	"blockchain/utxo"
+)
+
+const protocol = "tcp"
+
+// version of the client we are running
+// we only have one version so this doesnt really do anything
+const nodeVersion = 1
+
+// every message starts with a fixed size command name,
+// the rest of the message is the gob encoded payload
+const commandLength = 12
+
+// the most headers a headers message carries. If a peer gets this
+// many it asks for more, starting from the last one
+const maxHeadersPerMsg = 2000
+
+// the biggest message we read from a peer, anything longer gets dropped
+// instead of filling up memory. Blocks are the big ones
+const maxMessageSize = 32 << 20
+
+// how long a peer has to send its whole message, so a connection
+// that stops sending doesn't hang around forever
+const readTimeout = 30 * time.Second
+
+// a mining node waits until this many transactions are
+// sitting in its mempool before it bothers mining a block
+const minTxsPerBlock = 2
+
+// the address of this node, e.g. localhost:3000
+var nodeAddress string
+
+// if set, this node mines blocks and sends the reward here
+var miningAddress string
+
+// the first known node is the "central" node that every new
+// node connects to first. Others get added as they say hello
+var knownNodes = []string{"localhost:3000"}
+
+// hashes of blocks we have the headers of and asked a peer for but
+// haven't received yet, oldest first so each one extends our tip when it arrives
+var blocksInTransit = [][]byte{}
+
+// connections are handled in their own goroutines, but they all touch
+// the globals above (and the mempool) so we only let one message be processed at a time
+var serverLock sync.Mutex
+
+// When a new node is run, it gets several nodes from a DNS seed,
+// and sends them version message,
+// which in our implementation will look like this:
+type Version struct {
+	// version of the client we are running
+	Version int
+	// Length of this node's blockchain
+	BestHeight int
+	// The address of the sender
+	AddrFrom string
+}
+
+// asks a peer for the headers of its chain after the first block of
+// Locator it has on its main chain (see Blockchain.Locator). We sync
+// headers first and only then download the blocks they're for, and a
+// light client can stop at the headers
+type getheaders struct {
+	AddrFrom string
+	Locator  [][]byte
+}
+
+// serialized block headers, oldest first
+type headersMsg struct {
+	AddrFrom string
+	Headers  [][]byte
+}
+
+// inventory, a list of block or transaction hashes the sender has.
+// Type is either "block" or "tx"
+type inv struct {
+	AddrFrom string
+	Type     string
+	Items    [][]byte
+}
+
+// a request for one specific block or transaction
+type getdata struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
+}
+
+// a whole serialized block
+type blockMsg struct {
+	AddrFrom string
+	Block    []byte
+}
+
+// a whole serialized transaction
+type txMsg struct {
+	AddrFrom    string
+	Transaction []byte
+}
+
+// starts listening on localhost:port and handles messages from
+// other nodes forever. dbPath is the database file this node uses.
+// Only returns if the node can't start or stops accepting connections
+func Start(dbPath, port, minerAddress string) error {
+	nodeAddress = fmt.Sprintf("localhost:%s", port)
+	miningAddress = minerAddress
+	ln, err := net.Listen(protocol, nodeAddress)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	bc, err := chain.OpenBlockchain(dbPath)
+	if err != nil {
+		return err
+	}
+	defer bc.DB.Close()
+
+	// if current node is not the central one, it must send version message
+	// to the central node to find out if its blockchain is outdated.
+	if nodeAddress != knownNodes[0] {
+		sendVersion(knownNodes[0], bc)
+	}
+
+	// transactions made with send -mine=false while the node was
+	// down are still in the mempool, let the network know about them
+	mempool := utxo.Mempool{
+		Blockchain: bc,
+	}
+	txs, err := mempool.Transactions()
+	if err != nil {
+		return err
+	}
+	var pending [][]byte
+	for _, transaction := range txs {
+		pending = append(pending, transaction.ID)
+	}
+	if len(pending) > 0 && nodeAddress != knownNodes[0] {
+		sendInv(knownNodes[0], "tx", pending)
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go handleConnection(conn, bc)
+	}
+}
+
+// pads the command out to commandLength bytes
+func commandToBytes(command string) []byte {
+	var b [commandLength]byte
+	copy(b[:], command)
+	return b[:]
+}
+
+// opposite of commandToBytes, strips the padding back off
+func bytesToCommand(b []byte) string {
+	return string(bytes.TrimRight(b, "\x00"))
+}
+
+// the messages are all plain structs, so like Block.Serialize
+// this only fails if one of them is broken
+func gobEncode(data interface{}) []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+func nodeIsKnown(addr string) bool {
+	for _, node := range knownNodes {
+		if node == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// opens a connection to addr and writes the whole message. If the
+// node isn't reachable we forget about it
+func sendData(addr string, data []byte) {
+	conn, err := net.Dial(protocol, addr)
+	if err != nil {
+		fmt.Printf("%s is not available\n", addr)
+		var updatedNodes []string
+		for _, node := range knownNodes {
+			if node != addr {
+				updatedNodes = append(updatedNodes, node)
+			}
+		}
+		knownNodes = updatedNodes
+		return
+	}
+	defer conn.Close()
+
+	_, err = io.Copy(conn, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Sending to %s failed: %s\n", addr, err)
+	}
+}
+
+func sendVersion(addr string, bc *chain.Blockchain) {
+	bestHeight := bc.GetBestHeight()
+	payload := gobEncode(Version{nodeVersion, bestHeight, nodeAddress})
+	request := append(commandToBytes("version"), payload...)
+	sendData(addr, request)
+}
+
+func sendGetHeaders(addr string, bc *chain.Blockchain) {
+	locator, err := bc.Locator()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	payload := gobEncode(getheaders{nodeAddress, locator})
+	request := append(commandToBytes("getheaders"), payload...)
+	sendData(addr, request)
+}
+
+func sendHeaders(addr string, headers []*chain.BlockHeader) {
+	var serialized [][]byte
+	for _, header := range headers {
+		serialized = append(serialized, header.Serialize())
+	}
+	payload := gobEncode(headersMsg{nodeAddress, serialized})
+	request := append(commandToBytes("headers"), payload...)
+	sendData(addr, request)
+}
+
+func sendInv(addr, kind string, items [][]byte) {
+	payload := gobEncode(inv{nodeAddress, kind, items})
+	request := append(commandToBytes("inv"), payload...)
+	sendData(addr, request)
+}
+
+func sendGetData(addr, kind string, id []byte) {
+	payload := gobEncode(getdata{nodeAddress, kind, id})
+	request := append(commandToBytes("getdata"), payload...)
+	sendData(addr, request)
+}
+
+func sendBlock(addr string, b *chain.Block) {
+	payload := gobEncode(blockMsg{nodeAddress, b.Serialize()})
+	request := append(commandToBytes("block"), payload...)
+	sendData(addr, request)
+}
+
+func sendTx(addr string, transaction *tx.Transaction) {
+	payload := gobEncode(txMsg{nodeAddress, transaction.Serialize()})
+	request := append(commandToBytes("tx"), payload...)
+	sendData(addr, request)
+}
+
+// reads the entire message, then looks at the command to
+// figure out which handler gets the payload
+func handleConnection(conn net.Conn, bc *chain.Blockchain) {
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	// one byte more than allowed, so we can tell a message that's too big
+	request, err := io.ReadAll(io.LimitReader(conn, maxMessageSize+1))
+	conn.Close()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(request) > maxMessageSize {
+		log.Printf("Message from %s is over %d bytes, ignoring\n", conn.RemoteAddr(), maxMessageSize)
+		return
+	}
+	if len(request) < commandLength {
+		log.Println("Message too short, ignoring")
+		return
+	}
+
+	serverLock.Lock()
+	defer serverLock.Unlock()
+
+	command := bytesToCommand(request[:commandLength])
+	payload := request[commandLength:]
+	fmt.Printf("Received %s command\n", command)
+
+	switch command {
+	case "version":
+		handleVersion(payload, bc)
+	case "getheaders":
+		handleGetHeaders(payload, bc)
+	case "headers":
+		handleHeaders(payload, bc)
+	case "inv":
+		handleInv(payload, bc)
+	case "getdata":
+		handleGetData(payload, bc)
+	case "block":
+		handleBlock(payload, bc)
+	case "tx":
+		handleTx(payload, bc)
+	default:
+		fmt.Println("Unknown command!")
+	}
+}
+
+// whoever has the longer chain is the one the other should download from.
+// If the peer is ahead we ask for its headers, if we're ahead we tell it
+// our version so it asks for ours
+func handleVersion(payload []byte, bc *chain.Blockchain) {
+	var msg Version
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+
+	myBestHeight := bc.GetBestHeight()
+	if myBestHeight < msg.BestHeight {
+		sendGetHeaders(msg.AddrFrom, bc)
+	} else if myBestHeight > msg.BestHeight {
+		sendVersion(msg.AddrFrom, bc)
+	}
+
+	if !nodeIsKnown(msg.AddrFrom) {
+		knownNodes = append(knownNodes, msg.AddrFrom)
+	}
+}
+
+// replies with the headers of our chain after the one the peer has
+func handleGetHeaders(payload []byte, bc *chain.Blockchain) {
+	var msg getheaders
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+
+	headers, err := bc.HeadersAfter(msg.Locator, maxHeadersPerMsg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(headers) > 0 {
+		sendHeaders(msg.AddrFrom, headers)
+	}
+}
+
+// checks and stores the headers, then downloads the blocks that go with
+// them one at a time. A full message means the peer has more headers,
+// so we get all of those before asking for any blocks
+func handleHeaders(payload []byte, bc *chain.Blockchain) {
+	var msg headersMsg
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Printf("Received %d headers\n", len(msg.Headers))
+
+	for _, data := range msg.Headers {
+		header, err := chain.DeserializeHeader(data)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if err := bc.AddHeader(header); err != nil {
+			log.Printf("Rejected header %x: %s\n", header.Hash(), err)
+			break
+		}
+	}
+	if len(msg.Headers) == maxHeadersPerMsg {
+		sendGetHeaders(msg.AddrFrom, bc)
+		return
+	}
+
+	missing, err := bc.MissingBlocks()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	blocksInTransit = missing
+	if len(blocksInTransit) > 0 {
+		sendGetData(msg.AddrFrom, "block", blocksInTransit[0])
+		blocksInTransit = blocksInTransit[1:]
+	}
+}
+
+func handleInv(payload []byte, bc *chain.Blockchain) {
+	var msg inv
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Printf("Received inventory with %d %s\n", len(msg.Items), msg.Type)
+
+	// a block we don't have means the peer is ahead, so get the
+	// headers first. The blocks get asked for once they're checked
+	if msg.Type == "block" {
+		for _, hash := range msg.Items {
+			if !bc.HasBlock(hash) {
+				sendGetHeaders(msg.AddrFrom, bc)
+				break
+			}
+		}
+	}
+
+	if msg.Type == "tx" {
+		mempool := utxo.Mempool{
+			Blockchain: bc,
+		}
+		for _, txID := range msg.Items {
+			_, ok, err := mempool.Get(txID)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if !ok {
+				sendGetData(msg.AddrFrom, "tx", txID)
+			}
+		}
+	}
+}
+
+func handleGetData(payload []byte, bc *chain.Blockchain) {
+	var msg getdata
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if msg.Type == "block" {
+		block, err := bc.GetBlock(msg.ID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		sendBlock(msg.AddrFrom, block)
+	}
+
+	if msg.Type == "tx" {
+		mempool := utxo.Mempool{
+			Blockchain: bc,
+		}
+		transaction, ok, err := mempool.Get(msg.ID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if !ok {
+			return
+		}
+		sendTx(msg.AddrFrom, &transaction)
+	}
+}
+
+// adds the block we received to our chain (or a side chain, which we
+// switch to if it has more work) and asks for the next one
+func handleBlock(payload []byte, bc *chain.Blockchain) {
+	var msg blockMsg
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+
+	block, err := chain.DeserializeBlock(msg.Block)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	// the UTXO set goes along with every block that goes on or
+	// comes off, so a block spending what isn't there gets rejected
+	update, err := bc.ImportBlock(block, &utxo.UTXOSet{Blockchain: bc})
+	if err != nil {
+		log.Printf("Rejected block %x: %s\n", block.Hash, err)
+		blocksInTransit = [][]byte{}
+
+		// we're probably missing some blocks in between,
+		// so start over from the headers
+		if len(block.PrevBlockHash) != 0 && !bc.HasBlock(block.PrevBlockHash) {
+			sendGetHeaders(msg.AddrFrom, bc)
+		}
+		return
+	}
+	switch {
+	case len(update.Disconnected) > 0:
+		fmt.Printf("Added block %x and switched to its chain, %d blocks came off ours and %d went on\n", block.Hash, len(update.Disconnected), len(update.Connected))
+	case len(update.Connected) > 0:
+		fmt.Printf("Added block %x\n", block.Hash)
+	default:
+		fmt.Printf("Added block %x to a side chain\n", block.Hash)
+	}
+
+	// anything in our mempool that made it into a block is done, and
+	// whatever came off the main chain can go back in
+	if err := utxo.ApplyChainUpdate(bc, update); err != nil {
+		log.Println(err)
+	}
+
+	if len(blocksInTransit) > 0 {
+		sendGetData(msg.AddrFrom, "block", blocksInTransit[0])
+		blocksInTransit = blocksInTransit[1:]
+	}
+}
+
+// puts the transaction in the mempool and tells everyone else about it.
+// Mining nodes start mining once enough transactions have piled up
+func handleTx(payload []byte, bc *chain.Blockchain) {
+	var msg txMsg
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg); err != nil {
+		log.Println(err)
+		return
+	}
+
+	transaction, err := tx.DeserializeTransaction(msg.Transaction)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	mempool := utxo.Mempool{
+		Blockchain: bc,
+	}
+	if err := mempool.Add(&transaction); err != nil {
+		log.Printf("Rejected transaction %x: %s\n", transaction.ID, err)
+		return
+	}
+
+	for _, node := range knownNodes {
+		if node != nodeAddress && node != msg.AddrFrom {
+			sendInv(node, "tx", [][]byte{transaction.ID})
+		}
+	}
+
+	pending, err := mempool.Transactions()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if miningAddress != "" && len(pending) >= minTxsPerBlock {
+		if err := mineMempool(bc); err != nil {
+			log.Printf("Mining failed: %s\n", err)
+		}
+	}
+}
+
+// packs every transaction in the mempool that isn't locked into a new
+// block along with a coinbase paying miningAddress, then announces the block
+func mineMempool(bc *chain.Blockchain) error {
+	mempool := utxo.Mempool{
+		Blockchain: bc,
+	}
+
+	pending, err := mempool.Ready()
+	if err != nil {
+		return err
+	}
+	var txs []*tx.Transaction
+	for _, transaction := range pending {
+		if err := bc.VerifyTransaction(transaction); err == nil {
+			txs = append(txs, transaction)
+		} else {
+			log.Printf("Dropping transaction %x: %s\n", transaction.ID, err)
+			if err := mempool.Remove(transaction.ID); err != nil {
+				return err
+			}
+		}
+	}
+	if len(txs) == 0 {
+		fmt.Println("No transactions are valid and unlocked! Waiting for new ones...")
+		return nil
+	}
+
+	block, err := utxo.MineBlock(bc, txs, miningAddress)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Mined new block %x\n", block.Hash)
+
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			sendInv(node, "block", [][]byte{block.Hash})
+		}
+	}
+	return nil
+}
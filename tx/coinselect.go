@@ -0,0 +1,182 @@
+package tx
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// an unspent output somebody can spend, and where it is
+type Coin struct {
+	Txid  []byte
+	Index int
+	Value int
+}
+
+// picks which coins pay for target. If they can't cover it all
+// together it gives back all of them, then the caller sees it's short
+type CoinSelector func(coins []Coin, target int) []Coin
+
+// what a CoinSelection picks from, the utxo package's UTXOSet is one
+// of these. Coins that pending transactions spend already are left out
+type CoinLister interface {
+	SpendableCoins(pubKeyHash []byte) ([]Coin, error)
+}
+
+// an OutputFinder that lets Select pick from the coins of Coins, so the
+// transaction constructors can use any strategy without knowing about it
+type CoinSelection struct {
+	Coins  CoinLister
+	Select CoinSelector
+}
+
+func (cs CoinSelection) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int, error) {
+	coins, err := cs.Coins.SpendableCoins(pubKeyHash)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	total := 0
+	outputs := make(map[string][]int)
+	for _, coin := range cs.Select(coins, amount) {
+		txID := hex.EncodeToString(coin.Txid)
+		outputs[txID] = append(outputs[txID], coin.Index)
+		total += coin.Value
+	}
+	return total, outputs, nil
+}
+
+// the strategies send -coinselect can pick by name
+var CoinSelectors = map[string]CoinSelector{
+	"accumulate": SelectAccumulate,
+	"largest":    SelectLargestFirst,
+	"bnb":        SelectBranchAndBound,
+	"minchange":  SelectMinimizeChange,
+}
+
+// looks up a strategy in CoinSelectors
+func FindCoinSelector(name string) (CoinSelector, error) {
+	if selector, ok := CoinSelectors[name]; ok {
+		return selector, nil
+	}
+	var names []string
+	for n := range CoinSelectors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown coin selection %q, it has to be one of %s", name, strings.Join(names, ", "))
+}
+
+// takes coins in the order they come until there's enough.
+// The simplest one, and what sending does unless told otherwise
+func SelectAccumulate(coins []Coin, target int) []Coin {
+	var selected []Coin
+	total := 0
+	for _, coin := range coins {
+		if total >= target && len(selected) > 0 {
+			break
+		}
+		selected = append(selected, coin)
+		total += coin.Value
+	}
+	return selected
+}
+
+// takes the biggest coins first, so as few inputs as possible get used
+func SelectLargestFirst(coins []Coin, target int) []Coin {
+	return SelectAccumulate(largestFirst(coins), target)
+}
+
+// looks for coins that add up to exactly target, so there's no change
+// output at all. It tries the combinations biggest coins first and gives
+// up on a branch as soon as it's over target. If there's no exact match
+// (or it takes too long to find one) it falls back to largest first
+func SelectBranchAndBound(coins []Coin, target int) []Coin {
+	if selected, ok := searchCoins(largestFirst(coins), target, true); ok {
+		return selected
+	}
+	return SelectLargestFirst(coins, target)
+}
+
+// the coins that cover target with the least change left over, fewest
+// coins if there's a tie. Like SelectBranchAndBound except any amount
+// over target is fine, the search just keeps the closest one
+func SelectMinimizeChange(coins []Coin, target int) []Coin {
+	if selected, ok := searchCoins(largestFirst(coins), target, false); ok {
+		return selected
+	}
+	return SelectLargestFirst(coins, target)
+}
+
+// how many combinations searchCoins looks at before it settles
+// for the best one so far, wallets can have a lot of coins
+const maxCoinSearchTries = 100000
+
+// depth first search over coins (biggest first) for a combination
+// covering target with the least left over. With exact only a
+// combination with nothing left over counts
+func searchCoins(coins []Coin, target int, exact bool) ([]Coin, bool) {
+	// nothing to pay for, but a transaction still needs an input
+	if target <= 0 {
+		return nil, false
+	}
+	// what's left from coin i on, to cut branches that can't reach target
+	remaining := make([]int, len(coins)+1)
+	for i := len(coins) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + coins[i].Value
+	}
+	if remaining[0] < target {
+		return nil, false
+	}
+
+	var best []Coin
+	bestExcess := -1
+	tries := 0
+	var current []Coin
+
+	var search func(i, total int) bool
+	search = func(i, total int) bool {
+		tries++
+		if tries > maxCoinSearchTries {
+			return true
+		}
+		if total >= target {
+			excess := total - target
+			if (!exact || excess == 0) && (bestExcess < 0 || excess < bestExcess || (excess == bestExcess && len(current) < len(best))) {
+				best = append([]Coin{}, current...)
+				bestExcess = excess
+			}
+			// adding more coins only makes it worse
+			return bestExcess == 0
+		}
+		if i == len(coins) || total+remaining[i] < target {
+			return false
+		}
+		// can't beat what we have if even this coin goes over by more
+		over := total + coins[i].Value - target
+		if (exact && over > 0) || (bestExcess >= 0 && over > bestExcess) {
+			return search(i+1, total)
+		}
+
+		current = append(current, coins[i])
+		done := search(i+1, total+coins[i].Value)
+		current = current[:len(current)-1]
+		if done {
+			return true
+		}
+		return search(i+1, total)
+	}
+	search(0, 0)
+
+	return best, best != nil
+}
+
+// a copy of coins sorted biggest first
+func largestFirst(coins []Coin) []Coin {
+	sorted := append([]Coin{}, coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+	return sorted
+}
@@ -0,0 +1,23 @@
+package tx
+
+import "errors"
+
+// the errors this package hands back instead of crashing the program.
+// Most of the time they come wrapped with more detail (which address,
+// which transaction...), so check for them with errors.Is
+var (
+	// the sender's unspent outputs don't add up to the amount plus the fee
+	ErrInsufficientFunds = errors.New("not enough balance")
+	// a transaction input's signature doesn't match its public key
+	ErrInvalidSignature = errors.New("invalid signature")
+	// a transaction (or one of the outputs it spends) isn't on the chain
+	ErrUnknownTransaction = errors.New("unknown transaction")
+	// the transaction's ID isn't the hash of the transaction, so it's
+	// claiming to be some other transaction
+	ErrInvalidID = errors.New("transaction ID does not match its contents")
+	// an output is worth less than nothing, or they add up to too much
+	ErrInvalidValue = errors.New("invalid output value")
+	// the transaction's lock time, or the relative lock of an output it
+	// spends, hasn't passed yet so it can't go in the next block
+	ErrLocked = errors.New("transaction is locked")
+)
@@ -0,0 +1,77 @@
+package tx
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"blockchain/wallet"
+)
+
+// signatures are r||s with each one padded to 32 bytes. Using Bytes()
+// on its own drops leading zeros, which made the split in half land in
+// the wrong place every once in a while
+const signatureLen = 64
+
+func encodeSignature(r, s *big.Int) []byte {
+	signature := make([]byte, signatureLen)
+	r.FillBytes(signature[:signatureLen/2])
+	s.FillBytes(signature[signatureLen/2:])
+	return signature
+}
+
+func parseSignature(signature []byte) (*big.Int, *big.Int, error) {
+	if len(signature) != signatureLen {
+		return nil, nil, fmt.Errorf("signature is %d bytes, it has to be %d", len(signature), signatureLen)
+	}
+	r := new(big.Int).SetBytes(signature[:signatureLen/2])
+	s := new(big.Int).SetBytes(signature[signatureLen/2:])
+	if r.Sign() == 0 || s.Sign() == 0 {
+		return nil, nil, errors.New("signature has a zero r or s")
+	}
+	return r, s, nil
+}
+
+// a Signature field can have a few signatures one after the other
+// (multisig inputs have M of them)
+func splitSignatures(signatures []byte) ([][]byte, error) {
+	if len(signatures) == 0 || len(signatures)%signatureLen != 0 {
+		return nil, fmt.Errorf("signatures are %d bytes, that isn't a multiple of %d", len(signatures), signatureLen)
+	}
+	var split [][]byte
+	for i := 0; i < len(signatures); i += signatureLen {
+		split = append(split, signatures[i:i+signatureLen])
+	}
+	return split, nil
+}
+
+// checks signatures for one input, hash is its sigHash and
+// lockTime the LockTime of the transaction it's on
+type sigChecker struct {
+	hash     []byte
+	lockTime int64
+}
+
+func (c sigChecker) CheckSig(signature, pubKey []byte) bool {
+	r, s, err := parseSignature(signature)
+	if err != nil {
+		return false
+	}
+	key, err := wallet.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return ecdsa.Verify(key, c.hash, r, s)
+}
+
+// like Bitcoin's CHECKLOCKTIMEVERIFY, the script can't look at the chain
+// itself, so it checks that the transaction is locked until at least
+// lockTime and the chain makes sure it doesn't get mined before then.
+// Heights and times can't be compared, so they have to be the same kind
+func (c sigChecker) CheckLockTime(lockTime int64) bool {
+	if lockTime < 0 || (lockTime < LockTimeThreshold) != (c.lockTime < LockTimeThreshold) {
+		return false
+	}
+	return c.lockTime >= lockTime
+}
@@ -0,0 +1,657 @@
+// transactions move coins around. Each one spends outputs of earlier
+// transactions (its inputs) and makes new outputs that someone else can spend
+package tx
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"blockchain/script"
+	"blockchain/utils"
+	"blockchain/wallet"
+)
+
+// a transaction consists of an ID and a lists of inputs + outputs
+type Transaction struct {
+	ID   []byte
+	Vin  []TXInput
+	Vout []TXOutput
+	// the transaction can't go in a block before this height, or before
+	// this Unix time if it's LockTimeThreshold or more (like Bitcoin's
+	// nLockTime). 0 means it can go in any block
+	LockTime int64
+}
+
+// lock times below this are block heights, the rest are Unix times.
+// Same as Bitcoin, 500000000 blocks is thousands of years away
+const LockTimeThreshold = 500000000
+
+// what a payment can be locked with, the zero value is a payment
+// that can be mined and spent right away
+type Locks struct {
+	// goes on the transaction, see Transaction.LockTime
+	LockTime int64
+	// goes on the output paying the recipient, see TXOutput.RelativeLock
+	RelativeLock int
+}
+
+// what NewGeneralTransaction uses to find coins that "from" can spend,
+// the utxo package's UTXOSet is one of these
+type OutputFinder interface {
+	FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int, error)
+}
+
+// what NewGeneralTransaction uses to look up the transactions the coins
+// came from, since signing needs them. chain.Blockchain is one of these
+type TransactionFinder interface {
+	FindTransaction(id []byte) (Transaction, error)
+}
+
+// coinbase transaction is a specific type of transaction
+// used to reward miners. It is not a normal transaction
+// in the sense that it accesses no previous outputs in its inputs
+// and also stores a subsidy (miner reward) as the value in its output
+// with a hash equal to the person who receives the reward, "to".
+// How much that is depends on the height of the block the coinbase goes
+// in plus the fees of the block, which is the chain's business, so the
+// caller works out value (see chain.BlockSubsidy)
+func NewCoinbaseTX(to, data string, value int) (*Transaction, error) {
+	txout, err := NewTXOutput(value, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// the data has to be different every time, otherwise two rewards to the
+	// same address would hash to the same transaction ID
+	if data == "" {
+		randData := make([]byte, 8)
+		_, err := rand.Read(randData)
+		if err != nil {
+			return nil, err
+		}
+		data = fmt.Sprintf("Reward to '%s' %x", to, randData)
+	}
+
+	// here we set the publicKey on the TXInput to be
+	// equal to some random string since it wasn't signed by anyone
+	// nor does it have to be verified (we will avoid calling
+	// verify on it using IsCoinbase() function)
+	txin := TXInput{
+		Txid:      []byte{},
+		OutputIdx: -1,
+		PublicKey: []byte(data),
+		Signature: nil,
+	}
+	tx := &Transaction{
+		ID:   nil,
+		Vin:  []TXInput{txin},
+		Vout: []TXOutput{txout},
+	}
+	tx.setID()
+	return tx, nil
+}
+
+// makes a new transaction object to
+// transfer x money from account a to b
+// the inputs "spend" the money from the sender
+// and the output is a new unspent transaction with "amount" money
+// unlockable only by the receiver's address, "to".
+// The fee is whatever the inputs are worth minus what the outputs are worth,
+// so to pay a fee we just hand back that much less change.
+// fromWallet holds the keys of the sender, we need them to sign
+func NewGeneralTransaction(fromWallet *wallet.Wallet, to string, amount, fee int, locks Locks, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	output, err := newPayment(to, amount, locks)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletSpend(fromWallet, []TXOutput{output}, fee, locks.LockTime, utxos, txs)
+}
+
+// like NewGeneralTransaction but spends coins sent to the multisig ms.
+// signers are the wallets with keys of ms in the same order as ms has
+// them (see Wallets.Signers), and there have to be at least M of them
+func NewMultisigTransaction(ms *wallet.MultisigScript, signers []*wallet.Wallet, to string, amount, fee int, locks Locks, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	output, err := newPayment(to, amount, locks)
+	if err != nil {
+		return nil, err
+	}
+	return newMultisigSpend(ms, signers, []TXOutput{output}, fee, locks.LockTime, utxos, txs)
+}
+
+// makes and signs a transaction sending from an address in wallets,
+// whether it's a normal address or a multisig they have enough keys for
+func NewTransactionFromWallets(wallets *wallet.Wallets, from, to string, amount, fee int, locks Locks, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	output, err := newPayment(to, amount, locks)
+	if err != nil {
+		return nil, err
+	}
+	return newSpendFromWallets(wallets, from, []TXOutput{output}, fee, locks.LockTime, utxos, txs)
+}
+
+// one recipient of a NewBatchTransaction
+type Payment struct {
+	To     string
+	Amount int
+}
+
+// like NewTransactionFromWallets but pays several addresses at once, each
+// gets its own output (in the order of payments) and there's one change
+// output at the end. That's one fee instead of one for every payment
+func NewBatchTransaction(wallets *wallet.Wallets, from string, payments []Payment, fee int, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("a batch needs at least one payment")
+	}
+	var outputs []TXOutput
+	for _, payment := range payments {
+		output, err := newPayment(payment.To, payment.Amount, Locks{})
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return newSpendFromWallets(wallets, from, outputs, fee, 0, utxos, txs)
+}
+
+// a transaction from an address in wallets that puts data on the chain
+// (see NewDataOutput). It doesn't pay anybody, the inputs just cover the fee
+// and the rest goes back as change
+func NewAnchorTransaction(wallets *wallet.Wallets, from string, data []byte, fee int, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	output, err := NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+	return newSpendFromWallets(wallets, from, []TXOutput{output}, fee, 0, utxos, txs)
+}
+
+// the output paying amount to "to", with the relative lock from locks
+func newPayment(to string, amount int, locks Locks) (TXOutput, error) {
+	if amount <= 0 {
+		return TXOutput{}, fmt.Errorf("the payment to %s has to be more than 0, not %d", to, amount)
+	}
+	if locks.LockTime < 0 || locks.RelativeLock < 0 {
+		return TXOutput{}, fmt.Errorf("lock times can't be negative")
+	}
+	output, err := NewTXOutput(amount, to)
+	if err != nil {
+		return TXOutput{}, err
+	}
+	output.RelativeLock = locks.RelativeLock
+	return output, nil
+}
+
+// picks whichever of newWalletSpend and newMultisigSpend goes with from
+func newSpendFromWallets(wallets *wallet.Wallets, from string, outputs []TXOutput, fee int, lockTime int64, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	version, _, err := wallet.DecodeAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	if version == wallet.MultisigVersion {
+		ms, err := wallets.FindMultisig(from)
+		if err != nil {
+			return nil, err
+		}
+		return newMultisigSpend(ms, wallets.Signers(ms), outputs, fee, lockTime, utxos, txs)
+	}
+
+	fromWallet, err := wallets.FindWallet(from)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletSpend(&fromWallet, outputs, fee, lockTime, utxos, txs)
+}
+
+// spends fromWallet's coins on outputs and the fee, and signs it
+func newWalletSpend(fromWallet *wallet.Wallet, outputs []TXOutput, fee int, lockTime int64, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	// need this since we wanna try to unlock unspent transactions
+	// code word for verifying the digital signatures
+	pubKeyHash := wallet.HashPubKey(fromWallet.PublicKey)
+	from := TXOutput{PublicKeyHash: pubKeyHash}
+
+	tx, err := newSpend(from, fromWallet.PublicKey, outputs, fee, lockTime, utxos)
+	if err != nil {
+		return nil, err
+	}
+	prevTXs, err := findPrevTXs(tx, txs)
+	if err != nil {
+		return nil, err
+	}
+
+	// sign the whole transaction, aka imprint our privateKey on it
+	// this will auto populate the TXInput's "Signature" field
+	err = tx.Sign(fromWallet.PrivateKey, prevTXs)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// newWalletSpend for the multisig ms, M of the signers sign it
+func newMultisigSpend(ms *wallet.MultisigScript, signers []*wallet.Wallet, outputs []TXOutput, fee int, lockTime int64, utxos OutputFinder, txs TransactionFinder) (*Transaction, error) {
+	if len(signers) < ms.M {
+		return nil, fmt.Errorf("%w: %s needs %d signatures but only %d of its keys are here", ErrInvalidSignature, ms.Address(), ms.M, len(signers))
+	}
+	from := TXOutput{PublicKeyHash: ms.Hash(), Multisig: true}
+
+	// the whole script goes on the inputs so it can be checked against
+	// the hash the outputs are locked with
+	tx, err := newSpend(from, ms.Serialize(), outputs, fee, lockTime, utxos)
+	if err != nil {
+		return nil, err
+	}
+	prevTXs, err := findPrevTXs(tx, txs)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []ecdsa.PrivateKey
+	for _, signer := range signers[:ms.M] {
+		keys = append(keys, signer.PrivateKey)
+	}
+	err = tx.SignMultisig(keys, prevTXs)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// the unsigned part of making a transaction: spends enough of from's
+// coins to pay for the outputs plus the fee, with the change going back
+// to from. inputKey goes on every input to show what from is locked with
+func newSpend(from TXOutput, inputKey []byte, payments []TXOutput, fee int, lockTime int64, utxos OutputFinder) (*Transaction, error) {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	if fee < 0 {
+		return nil, fmt.Errorf("the fee can't be negative, not %d", fee)
+	}
+	// same as OutputTotal, the payments can't wrap around into
+	// something small that a few coins cover. Only a data output
+	// is allowed to be worth nothing
+	amount := 0
+	for idx, payment := range payments {
+		if payment.Value < 0 || (payment.Value == 0 && !payment.IsUnspendable()) {
+			return nil, fmt.Errorf("%w: payment %d is worth %d", ErrInvalidValue, idx+1, payment.Value)
+		}
+		if amount > math.MaxInt-fee-payment.Value {
+			return nil, fmt.Errorf("%w: the payments and fee add up to more than %d", ErrInvalidValue, math.MaxInt)
+		}
+		amount += payment.Value
+	}
+
+	amountOwned, outputTransactions, err := utxos.FindSpendableOutputs(from.PublicKeyHash, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if enough money
+	if amountOwned < amount+fee || len(outputTransactions) == 0 {
+		return nil, fmt.Errorf("%w: %s has %d but needs %d", ErrInsufficientFunds, from.Address(), amountOwned, amount+fee)
+	}
+
+	// take all the output transactions used to get this balance
+	// and make it the new inputs
+	for txid, listIdxes := range outputTransactions {
+		txidbytes, _ := hex.DecodeString(txid)
+		for _, idx := range listIdxes {
+			input := TXInput{
+				Txid:      txidbytes,
+				OutputIdx: idx,
+				PublicKey: inputKey,
+				Signature: nil, // signing this transaction will populate this fie
+			}
+			inputs = append(inputs, input)
+		}
+	}
+
+	// make ScriptPubKey "to" so that the money belongs to "to" now
+	for _, payment := range payments {
+		if !payment.IsUnspendable() {
+			outputs = append(outputs, payment)
+		}
+	}
+
+	// if we weren't exact (which is likely, say we needed to send 50 but we had
+	// only +20 and +40) then we refund the extra 10 back to the sender, "from"
+	if amountOwned > amount+fee {
+		change := from
+		change.Value = amountOwned - amount - fee
+		outputs = append(outputs, change)
+	}
+
+	// outputs nobody can spend go last. The UTXO set leaves them out,
+	// and that way the outputs it keeps are still at the same index
+	for _, payment := range payments {
+		if payment.IsUnspendable() {
+			outputs = append(outputs, payment)
+		}
+	}
+
+	tx := &Transaction{
+		ID:       nil,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: lockTime,
+	}
+
+	// this populates the ID field
+	tx.setID()
+	return tx, nil
+}
+
+// signing needs the transactions whose outputs we're spending
+func findPrevTXs(tx *Transaction, txs TransactionFinder) (map[string]Transaction, error) {
+	prevTXs := make(map[string]Transaction)
+	for _, vin := range tx.Vin {
+		prevTX, err := txs.FindTransaction(vin.Txid)
+		if err != nil {
+			return nil, err
+		}
+		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	}
+	return prevTXs, nil
+}
+
+// sets the transaction ID on a transaction to the sha256 hash of the
+// entire transaction
+func (tx *Transaction) setID() {
+	hash := sha256.Sum256(tx.HashBytes())
+	tx.ID = hash[:]
+}
+
+// what the outputs are worth together. A negative output would let the
+// others be worth more than the inputs, and so would adding up to more
+// than an int can hold, so either one gives back ErrInvalidValue
+func (tx *Transaction) OutputTotal() (int, error) {
+	total := 0
+	for idx, vout := range tx.Vout {
+		if vout.Value < 0 {
+			return 0, fmt.Errorf("%w: output %d of %x is worth %d", ErrInvalidValue, idx, tx.ID, vout.Value)
+		}
+		if total > math.MaxInt-vout.Value {
+			return 0, fmt.Errorf("%w: the outputs of %x add up to more than %d", ErrInvalidValue, tx.ID, math.MaxInt)
+		}
+		total += vout.Value
+	}
+	return total, nil
+}
+
+// the ID is only set by whoever made the transaction, so anything that came
+// from somebody else has to be checked with this. Otherwise it could claim
+// the ID of another transaction and take its place in the UTXO set.
+// The ID gets set before the transaction is signed (see newSpend), so
+// it's the hash of the transaction without its signatures
+func (tx *Transaction) CheckID() error {
+	unsigned := *tx
+	unsigned.Vin = make([]TXInput, len(tx.Vin))
+	for idx, vin := range tx.Vin {
+		vin.Signature = nil
+		unsigned.Vin[idx] = vin
+	}
+	hash := sha256.Sum256(unsigned.HashBytes())
+	if !bytes.Equal(tx.ID, hash[:]) {
+		return fmt.Errorf("%w: %x hashes to %x", ErrInvalidID, tx.ID, hash)
+	}
+	return nil
+}
+
+// gob hands out IDs to types the first time it sees them, so the bytes
+// it produces depend on whatever else the program happened to encode
+// before. That's fine for storing things, but hashes have to come out the
+// same on every node, so for those we write the fields out ourselves in a
+// fixed order. The ID is left out since it's the hash of everything else.
+// Blocks use this for their Merkle tree too
+func (tx *Transaction) HashBytes() []byte {
+	var buff bytes.Buffer
+
+	buff.Write(utils.IntToBuffer(int64(len(tx.Vin))))
+	for _, vin := range tx.Vin {
+		utils.WriteBytes(&buff, vin.Txid)
+		buff.Write(utils.IntToBuffer(int64(vin.OutputIdx)))
+		utils.WriteBytes(&buff, vin.Signature)
+		utils.WriteBytes(&buff, vin.PublicKey)
+	}
+
+	buff.Write(utils.IntToBuffer(int64(len(tx.Vout))))
+	for _, vout := range tx.Vout {
+		buff.Write(utils.IntToBuffer(int64(vout.Value)))
+		utils.WriteBytes(&buff, vout.PublicKeyHash)
+		if vout.Multisig {
+			buff.WriteByte(1)
+		} else {
+			buff.WriteByte(0)
+		}
+		utils.WriteBytes(&buff, vout.Script)
+		buff.Write(utils.IntToBuffer(int64(vout.RelativeLock)))
+	}
+
+	buff.Write(utils.IntToBuffer(tx.LockTime))
+
+	return buff.Bytes()
+}
+
+// a lock time written out for people, "block 120" or the date
+func DescribeLockTime(lockTime int64) string {
+	if lockTime < LockTimeThreshold {
+		return fmt.Sprintf("block %d", lockTime)
+	}
+	return time.Unix(lockTime, 0).String()
+}
+
+// whether the transaction's lock time has passed for the block at height,
+// on top of blocks with the median time medianTime (see
+// chain.MedianTimePast). Time locks go by that since the miner picks
+// the new block's own timestamp, and can push it ahead a bit
+func (tx *Transaction) IsFinal(height int, medianTime int64) bool {
+	switch {
+	case tx.LockTime == 0:
+		return true
+	case tx.LockTime < LockTimeThreshold:
+		return int64(height) >= tx.LockTime
+	default:
+		return medianTime >= tx.LockTime
+	}
+}
+
+// we can tell that a transaction is a coinbase type if
+// the vin array has length 1 and the OutputIdx is -1, and Txid of that transaction is
+// of length 0. Just as we set in NewCoinbaseTX
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].OutputIdx == -1
+}
+
+// prevTXs is just a map from transaction IDs (hex strings) to Transaction object
+// This func goes to each input in this transaction, finds the corresponding
+// Transaction which has the output, grabs the hash from that TXOutput
+// and gets an ID (by hashing the encoded Transaction object),
+// then we run ecdsa.Sign with that ID AS THE DATA,
+// and finally set the Signature field to whatever that value is. Phew.
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	if err := checkPrevTXs(tx, prevTXs); err != nil {
+		return err
+	}
+
+	txtrim := tx.TrimmedCopy()
+	for idx := range txtrim.Vin {
+		// perform the digital signature
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txtrim.sigHash(idx, prevTXs))
+
+		if err != nil {
+			return err
+		}
+
+		// get signature and store in original Transaction Input!
+		tx.Vin[idx].Signature = encodeSignature(r, s)
+	}
+	return nil
+}
+
+// Sign for inputs that spend multisig outputs. Every key in privKeys
+// signs each input, and the signatures go one after the other in the
+// Signature field. They have to be in the same order as the keys of the
+// multisig, since Verify goes through both lists once
+func (tx *Transaction) SignMultisig(privKeys []ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	if err := checkPrevTXs(tx, prevTXs); err != nil {
+		return err
+	}
+
+	txtrim := tx.TrimmedCopy()
+	for idx := range txtrim.Vin {
+		hash := txtrim.sigHash(idx, prevTXs)
+		var signatures []byte
+		for _, privKey := range privKeys {
+			r, s, err := ecdsa.Sign(rand.Reader, &privKey, hash)
+			if err != nil {
+				return err
+			}
+			signatures = append(signatures, encodeSignature(r, s)...)
+		}
+		tx.Vin[idx].Signature = signatures
+	}
+	return nil
+}
+
+// what gets signed for input idx, tx has to be a TrimmedCopy.
+// The PublicKey of that input is set to the hash of the output it spends
+// (the rest stay empty) and the whole thing is hashed. That way each
+// signature covers all the inputs and outputs, and which coins it spends
+func (tx *Transaction) sigHash(idx int, prevTXs map[string]Transaction) []byte {
+	// find the Transaction referenced by each TXInput
+	vin := tx.Vin[idx]
+	prevTX := prevTXs[hex.EncodeToString(vin.Txid)]
+
+	// Sets the PublicKey field on the TXInput to the PublicKeyHash
+	// on the output of some previous Transaction object
+	tx.Vin[idx].PublicKey = prevTX.Vout[vin.OutputIdx].PublicKeyHash
+
+	// set the ID of the Transaction to the hash
+	tx.setID()
+
+	// set it back to nil
+	tx.Vin[idx].PublicKey = nil
+	return tx.ID
+}
+
+// every input has to point at an output of a transaction in prevTXs,
+// otherwise there's nothing to sign or verify it against
+func checkPrevTXs(tx *Transaction, prevTXs map[string]Transaction) error {
+	for _, vin := range tx.Vin {
+		prevTX, ok := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !ok || prevTX.ID == nil {
+			return fmt.Errorf("%w: %x", ErrUnknownTransaction, vin.Txid)
+		}
+		if vin.OutputIdx < 0 || vin.OutputIdx >= len(prevTX.Vout) {
+			return fmt.Errorf("%w: %x has no output %d", ErrUnknownTransaction, vin.Txid, vin.OutputIdx)
+		}
+	}
+	return nil
+}
+
+// TrimmedCopy creates a trimmed copy of Transaction to be used in signing
+// literally the same as before except no Signature and PubKey fields on Inputs
+func (tx *Transaction) TrimmedCopy() Transaction {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	for _, vin := range tx.Vin {
+		inputs = append(inputs, TXInput{vin.Txid, vin.OutputIdx, nil, nil})
+	}
+
+	outputs = append(outputs, tx.Vout...)
+
+	txCopy := Transaction{
+		ID:       tx.ID,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: tx.LockTime,
+	}
+
+	return txCopy
+}
+
+// this verifies each TXInput on a transaction object by running the
+// script of the output it spends (see the script package), with the
+// signatures and public key on the input pushed on the stack first.
+// this function probably should be called after Sign(), otherwise
+// it makes no sense. A nil error means every input checked out
+func (tx *Transaction) Verify(prevTXs map[string]Transaction) error {
+
+	// no sense in verifying coinbase transactions
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	// without inputs nobody signed it, and it could make
+	// outputs out of nothing
+	if len(tx.Vin) == 0 {
+		return fmt.Errorf("%w: transaction %x has no inputs", ErrInvalidSignature, tx.ID)
+	}
+
+	// check to see if all transactions have IDs on them, they should by now
+	if err := checkPrevTXs(tx, prevTXs); err != nil {
+		return err
+	}
+
+	txtrim := tx.TrimmedCopy()
+
+	// verify EACH input
+	for idx, vin := range tx.Vin {
+		prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.OutputIdx]
+
+		// the standard script checks the input's key hashes to what the
+		// output is locked with, otherwise anyone could sign with their
+		// own key and spend it. Multisig outputs check their script's hash
+		lock, err := prevOut.LockingScript(vin.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: input %d of transaction %x: %s", ErrInvalidSignature, idx, tx.ID, err)
+		}
+		unlock, err := vin.UnlockingScript(prevOut.Multisig && len(prevOut.Script) == 0)
+		if err != nil {
+			return fmt.Errorf("%w: input %d of transaction %x: %s", ErrInvalidSignature, idx, tx.ID, err)
+		}
+
+		// same procedure as in Sign
+		checker := sigChecker{hash: txtrim.sigHash(idx, prevTXs), lockTime: tx.LockTime}
+		if err := script.Verify(unlock, lock, checker); err != nil {
+			return fmt.Errorf("%w: input %d of transaction %x: %s", ErrInvalidSignature, idx, tx.ID, err)
+		}
+	}
+	return nil
+}
+
+// like Block.Serialize, this only fails if the struct is broken
+func (tx *Transaction) Serialize() []byte {
+	var output bytes.Buffer
+	enc := gob.NewEncoder(&output)
+	err := enc.Encode(tx)
+	if err != nil {
+		log.Panic("Encode err:", err)
+	}
+	return output.Bytes()
+}
+
+// opposite of Serialize, used when a transaction comes in over the network
+func DeserializeTransaction(data []byte) (Transaction, error) {
+	var transaction Transaction
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&transaction)
+	if err != nil {
+		return transaction, fmt.Errorf("Decoding transaction: %w", err)
+	}
+
+	return transaction, nil
+}
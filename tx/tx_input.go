@@ -0,0 +1,54 @@
+package tx
+
+import (
+	"bytes"
+	"fmt"
+
+	"blockchain/script"
+	"blockchain/wallet"
+)
+
+// The first two fields has to deal with
+// the TXOutput that this TXInput references
+// a single TXInput can reference only one TXOutput
+// from a previous transaction
+type TXInput struct {
+	// this stores the ID of the transaction that
+	// the corresponding TXOutput belongs to
+	// The ID should be encoded to hex when
+	// used as a string
+	Txid []byte
+	// stores the index of an TXOutput in the transaction
+	OutputIdx int
+	Signature []byte
+	PublicKey []byte
+}
+
+// just hashes the public key on the input
+// and checks if its equal to the argument
+func (txi *TXInput) UsesKey(pubKeyHash []byte) bool {
+	lockingHash := wallet.HashPubKey(txi.PublicKey)
+	return bytes.Compare(lockingHash, pubKeyHash) == 0
+}
+
+// what gets pushed on the stack before the output's locking script runs:
+// the signatures, and then the public key. Multisig inputs have the
+// multisig script where the key would be, which goes into the locking
+// script instead (see TXOutput.LockingScript)
+func (txi *TXInput) UnlockingScript(multisig bool) ([]byte, error) {
+	signatures, err := splitSignatures(txi.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if len(txi.PublicKey) > script.MaxPushSize {
+		return nil, fmt.Errorf("public key is %d bytes, that's too big to push", len(txi.PublicKey))
+	}
+	var unlock []byte
+	for _, signature := range signatures {
+		unlock = append(unlock, script.Push(signature)...)
+	}
+	if !multisig {
+		unlock = append(unlock, script.Push(txi.PublicKey)...)
+	}
+	return unlock, nil
+}
@@ -0,0 +1,174 @@
+package tx
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"blockchain/script"
+	"blockchain/wallet"
+)
+
+type TXOutput struct {
+	// the amount of money
+	Value         int
+	PublicKeyHash []byte
+	// if set PublicKeyHash is the hash of a wallet.MultisigScript,
+	// and spending needs signatures from M of its keys
+	Multisig bool
+	// what the output is locked with, see the script package. Most outputs
+	// leave this empty and get the standard script for PublicKeyHash
+	// (see LockingScript). With a script of its own, PublicKeyHash
+	// doesn't decide who can spend it, so it doesn't belong to anyone
+	Script []byte
+	// the output can't be spent until this many blocks after the
+	// one it's in, like Bitcoin's relative lock times
+	RelativeLock int
+}
+
+// an output of value locked to address, whichever kind of address it is
+func NewTXOutput(value int, address string) (TXOutput, error) {
+	if value < 0 {
+		return TXOutput{}, fmt.Errorf("%w: an output can't be worth %d", ErrInvalidValue, value)
+	}
+	version, hash, err := wallet.DecodeAddress(address)
+	if err != nil {
+		return TXOutput{}, err
+	}
+	return TXOutput{
+		Value:         value,
+		PublicKeyHash: hash,
+		Multisig:      version == wallet.MultisigVersion,
+	}, nil
+}
+
+// an output worth nothing that puts data on the chain, like a document's
+// hash to prove it existed by the time the block was mined. It can
+// never be spent, so it doesn't go in the UTXO set
+func NewDataOutput(data []byte) (TXOutput, error) {
+	if len(data) == 0 || len(data) > script.MaxNullDataSize {
+		return TXOutput{}, fmt.Errorf("anchored data has to be between 1 and %d bytes, not %d", script.MaxNullDataSize, len(data))
+	}
+	return TXOutput{Value: 0, Script: script.NullData(data)}, nil
+}
+
+// whether the output starts with RETURN, so no input can ever spend it
+func (txo *TXOutput) IsUnspendable() bool {
+	return len(txo.Script) > 0 && txo.Script[0] == script.OP_RETURN
+}
+
+// the data a NewDataOutput carries, false for any other output
+func (txo *TXOutput) Data() ([]byte, bool) {
+	return script.NullDataPayload(txo.Script)
+}
+
+// the address the output is locked to, there isn't one for
+// outputs nobody can spend
+func (txo *TXOutput) Address() string {
+	if txo.IsUnspendable() {
+		return ""
+	}
+	if txo.Multisig {
+		return wallet.MultisigAddressFromHash(txo.PublicKeyHash)
+	}
+	return wallet.AddressFromPubKeyHash(txo.PublicKeyHash)
+}
+
+// the script that has to succeed to spend the output. redeem is the
+// multisig script the spender showed, which for multisig outputs has
+// to hash to PublicKeyHash
+func (txo *TXOutput) LockingScript(redeem []byte) ([]byte, error) {
+	if len(txo.Script) > 0 {
+		return txo.Script, nil
+	}
+	if !txo.Multisig {
+		return script.PayToPubKeyHash(txo.PublicKeyHash), nil
+	}
+
+	if !bytes.Equal(wallet.HashPubKey(redeem), txo.PublicKeyHash) {
+		return nil, errors.New("multisig script doesn't match the one the output is locked with")
+	}
+	ms, err := wallet.DeserializeMultisigScript(redeem)
+	if err != nil {
+		return nil, err
+	}
+	return script.Multisig(ms.M, ms.PubKeys), nil
+}
+
+// whether the key (or multisig script) hashing to pubKeyHash can spend
+// the output. Only outputs with the standard script count, anyone can
+// make an output with some other script and our PublicKeyHash on it,
+// and then it would look like ours without us being able to spend it
+func (txo *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
+	return len(txo.Script) == 0 && bytes.Equal(txo.PublicKeyHash, pubKeyHash)
+}
+
+func (txo *TXOutputs) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(txo)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// we store txoutputs (PLURAL) aka multiple outputs using gob encoder.
+// The UTXO set only keeps the unspent ones, so Indexes says where each
+// one is in its transaction: Outputs[i] is output Indexes[i], which is
+// what a TXInput's OutputIdx points at
+type TXOutputs struct {
+	Outputs []TXOutput
+	Indexes []int
+}
+
+// the index in its transaction of the i-th output
+func (txo *TXOutputs) Index(i int) int {
+	return txo.Indexes[i]
+}
+
+// adds output idx of its transaction, keeping them in index order
+func (txo *TXOutputs) Add(idx int, output TXOutput) {
+	pos := sort.SearchInts(txo.Indexes, idx)
+	txo.Indexes = append(txo.Indexes[:pos], append([]int{idx}, txo.Indexes[pos:]...)...)
+	txo.Outputs = append(txo.Outputs[:pos], append([]TXOutput{output}, txo.Outputs[pos:]...)...)
+}
+
+// output idx of the transaction, false if it isn't in here
+func (txo *TXOutputs) Find(idx int) (TXOutput, bool) {
+	for i, output := range txo.Outputs {
+		if txo.Index(i) == idx {
+			return output, true
+		}
+	}
+	return TXOutput{}, false
+}
+
+// takes output idx out and gives it back, false if it wasn't in here
+func (txo *TXOutputs) Remove(idx int) (TXOutput, bool) {
+	for i, output := range txo.Outputs {
+		if txo.Indexes[i] == idx {
+			txo.Outputs = append(txo.Outputs[:i], txo.Outputs[i+1:]...)
+			txo.Indexes = append(txo.Indexes[:i], txo.Indexes[i+1:]...)
+			return output, true
+		}
+	}
+	return TXOutput{}, false
+}
+
+func DeserializeOutputs(outputbytes []byte) (TXOutputs, error) {
+	var outputs TXOutputs
+
+	dec := gob.NewDecoder(bytes.NewReader(outputbytes))
+	err := dec.Decode(&outputs)
+	if err != nil {
+		return outputs, fmt.Errorf("Decoding outputs: %w", err)
+	}
+
+	return outputs, nil
+}
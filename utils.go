@@ -1,16 +0,0 @@
-package main
-
-import (
-	"bytes"
-	"encoding/binary"
-	"log"
-)
-
-func intToBuffer(i int64) []byte {
-	buff := new(bytes.Buffer)
-	err := binary.Write(buff, binary.BigEndian, i)
-	if err != nil {
-		log.Panic(err)
-	}
-	return buff.Bytes()
-}
@@ -0,0 +1,37 @@
+// helpers for turning numbers and byte slices into bytes
+// in a fixed way, used wherever something gets hashed
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// 8 bytes, big endian
+func IntToBuffer(i int64) []byte {
+	buff := make([]byte, 8)
+	binary.BigEndian.PutUint64(buff, uint64(i))
+	return buff
+}
+
+// writes b prefixed with its length, so that two different
+// lists of byte slices can never end up as the same bytes
+func WriteBytes(buff *bytes.Buffer, b []byte) {
+	buff.Write(IntToBuffer(int64(len(b))))
+	buff.Write(b)
+}
+
+// the opposite of WriteBytes, reads one length prefixed slice off the
+// front of data and gives back the slice and whatever comes after it
+func ReadBytes(data []byte) ([]byte, []byte, error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("not enough bytes for a length")
+	}
+	n := binary.BigEndian.Uint64(data[:8])
+	data = data[8:]
+	if n > uint64(len(data)) {
+		return nil, nil, errors.New("length is longer than the data")
+	}
+	return data[:n], data[n:], nil
+}
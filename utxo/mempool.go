@@ -0,0 +1,261 @@
+package utxo
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"blockchain/chain"
+	"blockchain/tx"
+
+	"github.com/boltdb/bolt"
+)
+
+const mempoolBucket = "mempool"
+
+// the mempool is where transactions wait until somebody mines them into
+// a block. We keep it in its own bucket in the same DB as the blockchain
+// so that pending transactions survive between runs of the program.
+// It lives next to the UTXO set since each needs the other: a pending
+// transaction has to spend unspent outputs, and outputs a pending
+// transaction spends can't be handed out again.
+// RULES:
+// 32-byte transaction hash -> Transaction (serialized)
+type Mempool struct {
+	Blockchain *chain.Blockchain
+}
+
+// checks that a transaction could go in a block and then saves it.
+// Its ID has to be its hash, since that's what it's stored under and
+// what the UTXO set will know its outputs by. Every input has to point
+// at an output in the UTXO set that no other pending transaction spends
+// already, the signatures have to check out, no output can be negative,
+// and the transaction can't create more money than its inputs are worth.
+// Whatever the inputs are worth on top of the outputs is the fee.
+// Transactions that are still locked get kept too, Ready leaves
+// them out until their locks have passed
+func (mp *Mempool) Add(transaction *tx.Transaction) error {
+	if transaction.IsCoinbase() {
+		return fmt.Errorf("Coinbase transactions can't go in the mempool")
+	}
+	if err := transaction.CheckID(); err != nil {
+		return err
+	}
+	_, ok, err := mp.Get(transaction.ID)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("Transaction %x is already in the mempool", transaction.ID)
+	}
+
+	utxoset := UTXOSet{
+		Blockchain: mp.Blockchain,
+	}
+	pendingSpent, err := mp.SpentOutputs()
+	if err != nil {
+		return err
+	}
+	thisSpent := make(map[string]bool)
+	inputTotal := 0
+
+	for _, vin := range transaction.Vin {
+		outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.OutputIdx)
+
+		output, ok, err := utxoset.FindOutput(vin.Txid, vin.OutputIdx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: input %s is not an unspent output", tx.ErrUnknownTransaction, outpoint)
+		}
+		if pendingSpent[hex.EncodeToString(vin.Txid)][vin.OutputIdx] {
+			return fmt.Errorf("Input %s is already spent by a pending transaction", outpoint)
+		}
+		if thisSpent[outpoint] {
+			return fmt.Errorf("Input %s is spent twice in the same transaction", outpoint)
+		}
+		thisSpent[outpoint] = true
+		inputTotal += output.Value
+	}
+
+	outputTotal, err := transaction.OutputTotal()
+	if err != nil {
+		return err
+	}
+	if outputTotal > inputTotal {
+		return fmt.Errorf("Transaction spends %d but its inputs are only worth %d", outputTotal, inputTotal)
+	}
+
+	if err := mp.Blockchain.VerifyTransaction(transaction); err != nil {
+		return fmt.Errorf("Transaction %x: %w", transaction.ID, err)
+	}
+
+	return mp.Blockchain.DB.Update(func(dbtx *bolt.Tx) error {
+		bucket, err := dbtx.CreateBucketIfNotExists([]byte(mempoolBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(transaction.ID, transaction.Serialize())
+	})
+}
+
+// gets a pending transaction given its ID, false if it isn't waiting
+func (mp *Mempool) Get(id []byte) (tx.Transaction, bool, error) {
+	var transaction tx.Transaction
+	found := false
+
+	err := mp.Blockchain.DB.View(func(dbtx *bolt.Tx) error {
+		bucket := dbtx.Bucket([]byte(mempoolBucket))
+		if bucket == nil {
+			return nil
+		}
+		if data := bucket.Get(id); data != nil {
+			var err error
+			transaction, err = tx.DeserializeTransaction(data)
+			if err != nil {
+				return err
+			}
+			found = true
+		}
+		return nil
+	})
+	return transaction, found, err
+}
+
+// every pending transaction, in the order Bolt keeps them (by ID)
+func (mp *Mempool) Transactions() ([]*tx.Transaction, error) {
+	var txs []*tx.Transaction
+
+	err := mp.Blockchain.DB.View(func(dbtx *bolt.Tx) error {
+		bucket := dbtx.Bucket([]byte(mempoolBucket))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			transaction, err := tx.DeserializeTransaction(v)
+			if err != nil {
+				return err
+			}
+			txs = append(txs, &transaction)
+		}
+		return nil
+	})
+	return txs, err
+}
+
+// the pending transactions that can go in the next block. The ones
+// waiting on a lock time or relative lock (see chain.CheckLocks) stay
+// in the mempool for a later block
+func (mp *Mempool) Ready() ([]*tx.Transaction, error) {
+	pending, err := mp.Transactions()
+	if err != nil {
+		return nil, err
+	}
+	var ready []*tx.Transaction
+	for _, transaction := range pending {
+		err := mp.Blockchain.CheckLocks(transaction)
+		if errors.Is(err, tx.ErrLocked) {
+			continue
+		}
+		// anything else wrong with it is for whoever mines it to find
+		ready = append(ready, transaction)
+	}
+	return ready, nil
+}
+
+// which outputs the pending transactions are spending, as a map
+// from transaction ID (hex) to the set of output indexes
+func (mp *Mempool) SpentOutputs() (map[string]map[int]bool, error) {
+	spent := make(map[string]map[int]bool)
+	txs, err := mp.Transactions()
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range txs {
+		for _, vin := range transaction.Vin {
+			txID := hex.EncodeToString(vin.Txid)
+			if spent[txID] == nil {
+				spent[txID] = make(map[int]bool)
+			}
+			spent[txID][vin.OutputIdx] = true
+		}
+	}
+	return spent, nil
+}
+
+// takes transactions out of the mempool
+func (mp *Mempool) Remove(ids ...[]byte) error {
+	return mp.Blockchain.DB.Update(func(dbtx *bolt.Tx) error {
+		bucket := dbtx.Bucket([]byte(mempoolBucket))
+		if bucket == nil {
+			return nil
+		}
+		for _, id := range ids {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// call this once a block has been added to the chain. Transactions that
+// made it into the block are done, and any pending transaction spending
+// the same outputs as the block did can never be mined, so it goes too
+func (mp *Mempool) RemoveBlockTransactions(block *chain.Block) error {
+	spentByBlock := make(map[string]bool)
+	var ids [][]byte
+	for _, transaction := range block.Transactions {
+		ids = append(ids, transaction.ID)
+		if transaction.IsCoinbase() {
+			continue
+		}
+		for _, vin := range transaction.Vin {
+			spentByBlock[fmt.Sprintf("%x:%d", vin.Txid, vin.OutputIdx)] = true
+		}
+	}
+
+	pending, err := mp.Transactions()
+	if err != nil {
+		return err
+	}
+	for _, transaction := range pending {
+		for _, vin := range transaction.Vin {
+			if spentByBlock[fmt.Sprintf("%x:%d", vin.Txid, vin.OutputIdx)] {
+				ids = append(ids, transaction.ID)
+				break
+			}
+		}
+	}
+	return mp.Remove(ids...)
+}
+
+// takes out the pending transactions that spend an output that isn't in
+// the UTXO set anymore. That happens when blocks come off the chain,
+// since the outputs they made are gone (coinbases in particular)
+func (mp *Mempool) RemoveOrphans() error {
+	utxoset := UTXOSet{
+		Blockchain: mp.Blockchain,
+	}
+	pending, err := mp.Transactions()
+	if err != nil {
+		return err
+	}
+
+	var ids [][]byte
+	for _, transaction := range pending {
+		for _, vin := range transaction.Vin {
+			_, ok, err := utxoset.FindOutput(vin.Txid, vin.OutputIdx)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				ids = append(ids, transaction.ID)
+				break
+			}
+		}
+	}
+	return mp.Remove(ids...)
+}
@@ -0,0 +1,40 @@
+package utxo
+
+import (
+	"blockchain/chain"
+	"blockchain/tx"
+)
+
+// mines transactions into a new block on top of the chain, with a coinbase
+// paying minerAddress the subsidy plus the fees of the transactions.
+// The UTXO set goes along with the block, then the mempool is brought
+// up to date with it
+func MineBlock(bc *chain.Blockchain, transactions []*tx.Transaction, minerAddress string) (*chain.Block, error) {
+	fees, err := bc.TotalFees(transactions)
+	if err != nil {
+		return nil, err
+	}
+	coinbase, err := tx.NewCoinbaseTX(minerAddress, "", chain.BlockSubsidy(bc.GetBestHeight()+1)+fees)
+	if err != nil {
+		return nil, err
+	}
+
+	// create and add new block to chain (this does the mining)
+	// the full slice expression makes append copy, so the caller's
+	// slice doesn't get the coinbase written into it
+	utxoset := UTXOSet{
+		Blockchain: bc,
+	}
+	block, err := bc.AddBlock(append(transactions[:len(transactions):len(transactions)], coinbase), &utxoset)
+	if err != nil {
+		return nil, err
+	}
+
+	mempool := Mempool{
+		Blockchain: bc,
+	}
+	if err := mempool.RemoveBlockTransactions(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
@@ -0,0 +1,42 @@
+package utxo
+
+import (
+	"blockchain/chain"
+)
+
+// brings the mempool up to date after a block was imported (see
+// chain.ImportBlock, the UTXO set went along already). The transactions
+// of blocks that came off the main chain go back in the mempool so they
+// can be mined again, unless the new chain already has them or spends
+// the same outputs
+func ApplyChainUpdate(bc *chain.Blockchain, update *chain.ChainUpdate) error {
+	mempool := Mempool{
+		Blockchain: bc,
+	}
+
+	for _, block := range update.Connected {
+		if err := mempool.RemoveBlockTransactions(block); err != nil {
+			return err
+		}
+	}
+
+	if len(update.Disconnected) > 0 {
+		if err := mempool.RemoveOrphans(); err != nil {
+			return err
+		}
+	}
+
+	// oldest first, so a transaction goes back before
+	// the ones in later blocks that might spend it
+	for i := len(update.Disconnected) - 1; i >= 0; i-- {
+		for _, transaction := range update.Disconnected[i].Transactions {
+			if transaction.IsCoinbase() {
+				continue
+			}
+			// Add checks everything again, so whatever the new
+			// chain made invalid just doesn't go back in
+			mempool.Add(transaction)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package utxo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"blockchain/chain"
+	"blockchain/tx"
+	"blockchain/wallet"
+
+	"github.com/boltdb/bolt"
+)
+
+// a new chain in a temporary directory, the genesis block paying miner
+func newTestChain(t *testing.T, miner *wallet.Wallet) (*chain.Blockchain, *UTXOSet) {
+	t.Helper()
+	bc, err := chain.InitBlockchain(string(miner.GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.DB.Close() })
+
+	utxoset := &UTXOSet{Blockchain: bc}
+	if err := utxoset.Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	return bc, utxoset
+}
+
+func newTestWallet(t *testing.T) *wallet.Wallet {
+	t.Helper()
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// mines a block on top of prev without putting it on the chain, like
+// another node would. The coinbase pays to, and there are no fees
+func mineOn(t *testing.T, bc *chain.Blockchain, prev *chain.Block, to string, transactions ...*tx.Transaction) *chain.Block {
+	t.Helper()
+	header := prev.Header()
+	bits, err := bc.RequiredBits(&header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp, err := bc.NextTimestamp(&header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase, err := tx.NewCoinbaseTX(to, "", chain.BlockSubsidy(prev.Height+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := chain.NewBlock(append(transactions, coinbase), prev.Hash, prev.Height+1, bits, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func addHeader(t *testing.T, bc *chain.Blockchain, block *chain.Block) {
+	t.Helper()
+	header := block.Header()
+	if err := bc.AddHeader(&header); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the UTXO set has to be exactly what building it from the chain gives
+func checkUTXOSet(t *testing.T, bc *chain.Blockchain) {
+	t.Helper()
+	want, err := bc.FindAllUnspentTXOs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bc.DB.View(func(dbtx *bolt.Tx) error {
+		b := dbtx.Bucket([]byte(UTXOSetbucket))
+		count := 0
+		err := b.ForEach(func(k, v []byte) error {
+			count++
+			outputs, ok := want[hex.EncodeToString(k)]
+			if !ok {
+				t.Errorf("UTXO set has %x, the chain doesn't", k)
+				return nil
+			}
+			if !bytes.Equal(v, outputs.Serialize()) {
+				t.Errorf("UTXO set has different outputs for %x than the chain", k)
+			}
+			return nil
+		})
+		if count != len(want) {
+			t.Errorf("UTXO set has %d transactions, the chain has %d", count, len(want))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkTip(t *testing.T, bc *chain.Blockchain, block *chain.Block) {
+	t.Helper()
+	if !bytes.Equal(bc.LatestHash, block.Hash) {
+		t.Fatalf("tip is %x, expected %x", bc.LatestHash, block.Hash)
+	}
+	if bc.GetBestHeight() != block.Height {
+		t.Fatalf("best height is %d, expected %d", bc.GetBestHeight(), block.Height)
+	}
+}
+
+func TestCompetingBranches(t *testing.T) {
+	miner, other := newTestWallet(t), newTestWallet(t)
+	minerAddress, otherAddress := string(miner.GetAddress()), string(other.GetAddress())
+	bc, utxoset := newTestChain(t, miner)
+	genesis, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two transactions spending the genesis coinbase, only one can make it
+	spend, err := tx.NewGeneralTransaction(miner, otherAddress, 4, 0, tx.Locks{}, utxoset, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doubleSpend, err := tx.NewGeneralTransaction(miner, otherAddress, 6, 0, tx.Locks{}, utxoset, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a1, err := MineBlock(bc, []*tx.Transaction{spend}, minerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUTXOSet(t, bc)
+
+	// a block at the same height is a tie, we stay on the chain we had
+	b1 := mineOn(t, bc, genesis, otherAddress)
+	update, err := bc.ImportBlock(b1, utxoset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Connected) != 0 || len(update.Disconnected) != 0 {
+		t.Fatalf("tie changed the main chain: %d on, %d off", len(update.Connected), len(update.Disconnected))
+	}
+	checkTip(t, bc, a1)
+	checkUTXOSet(t, bc)
+
+	// now b has more work, so a1 comes off and spend goes back in the mempool
+	b2 := mineOn(t, bc, b1, otherAddress)
+	update, err = bc.ImportBlock(b2, utxoset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Disconnected) != 1 || !bytes.Equal(update.Disconnected[0].Hash, a1.Hash) {
+		t.Fatalf("expected a1 to come off, %d blocks did", len(update.Disconnected))
+	}
+	if len(update.Connected) != 2 || !bytes.Equal(update.Connected[0].Hash, b1.Hash) || !bytes.Equal(update.Connected[1].Hash, b2.Hash) {
+		t.Fatalf("expected b1 and b2 to go on, %d blocks did", len(update.Connected))
+	}
+	checkTip(t, bc, b2)
+	checkUTXOSet(t, bc)
+
+	if err := ApplyChainUpdate(bc, update); err != nil {
+		t.Fatal(err)
+	}
+	mempool := Mempool{Blockchain: bc}
+	if _, ok, err := mempool.Get(spend.ID); err != nil || !ok {
+		t.Fatalf("spend didn't go back in the mempool (%v)", err)
+	}
+
+	// c goes on top of a1 and spends the genesis coinbase again in c3.
+	// Side chain blocks only get checked that far once c has the most
+	// work, then c3 can't go on and everything on top of it goes too
+	c2 := mineOn(t, bc, a1, minerAddress)
+	if _, err := bc.ImportBlock(c2, utxoset); err != nil {
+		t.Fatal(err)
+	}
+	c3 := mineOn(t, bc, c2, minerAddress, doubleSpend)
+	addHeader(t, bc, c3)
+	c4 := mineOn(t, bc, c3, minerAddress)
+	addHeader(t, bc, c4)
+	if _, err := bc.ImportBlock(c3, utxoset); err == nil {
+		t.Fatal("switched to a chain with a double spend")
+	}
+	checkTip(t, bc, b2)
+	checkUTXOSet(t, bc)
+	if bc.HasBlock(c3.Hash) || bc.HasHeader(c3.Hash) || bc.HasHeader(c4.Hash) {
+		t.Error("the invalid branch is still there")
+	}
+	if !bc.HasBlock(c2.Hash) || !bc.HasBlock(a1.Hash) {
+		t.Error("the valid blocks below the invalid one are gone")
+	}
+	best, err := bc.BestHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(best.Hash(), b2.Hash) {
+		t.Errorf("best header is %x, expected our tip %x", best.Hash(), b2.Hash)
+	}
+
+	// and mining one doesn't get anywhere either, in one block or two
+	if _, err := MineBlock(bc, []*tx.Transaction{spend, doubleSpend}, minerAddress); err == nil {
+		t.Fatal("mined a block spending the same output twice")
+	}
+	b3, err := MineBlock(bc, []*tx.Transaction{spend}, minerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MineBlock(bc, []*tx.Transaction{doubleSpend}, minerAddress); err == nil {
+		t.Fatal("mined a block spending an output that's spent already")
+	}
+	checkTip(t, bc, b3)
+	checkUTXOSet(t, bc)
+}
+
+func TestInvalidBranchLeavesTheBestHeaderElsewhere(t *testing.T) {
+	miner, other := newTestWallet(t), newTestWallet(t)
+	minerAddress, otherAddress := string(miner.GetAddress()), string(other.GetAddress())
+	bc, utxoset := newTestChain(t, miner)
+	genesis, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a1, err := MineBlock(bc, nil, minerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a chain of headers with the most work, we don't have its blocks yet
+	d := genesis
+	for i := 0; i < 3; i++ {
+		d = mineOn(t, bc, d, otherAddress)
+		addHeader(t, bc, d)
+	}
+
+	// c2 spends something that doesn't exist, which only gets
+	// noticed once c has more work than our chain and goes on
+	bogus := &tx.Transaction{
+		Vin:  []tx.TXInput{{Txid: []byte("nothing"), OutputIdx: 0}},
+		Vout: []tx.TXOutput{{Value: 1, PublicKeyHash: wallet.HashPubKey(other.PublicKey)}},
+	}
+	id := sha256.Sum256(bogus.HashBytes())
+	bogus.ID = id[:]
+	c1 := mineOn(t, bc, genesis, otherAddress)
+	if _, err := bc.ImportBlock(c1, utxoset); err != nil {
+		t.Fatal(err)
+	}
+	c2 := mineOn(t, bc, c1, otherAddress, bogus)
+	if _, err := bc.ImportBlock(c2, utxoset); err == nil {
+		t.Fatal("switched to a chain spending an output that doesn't exist")
+	}
+
+	checkTip(t, bc, a1)
+	checkUTXOSet(t, bc)
+	if bc.HasBlock(c2.Hash) || !bc.HasBlock(c1.Hash) {
+		t.Error("expected c2 gone and c1 still there")
+	}
+	best, err := bc.BestHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(best.Hash(), d.Hash) {
+		t.Errorf("best header is %x, expected the top of d %x", best.Hash(), d.Hash)
+	}
+}
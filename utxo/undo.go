@@ -0,0 +1,98 @@
+package utxo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+
+	"blockchain/chain"
+	"blockchain/tx"
+
+	"github.com/boltdb/bolt"
+)
+
+// hash of a block -> the outputs it spent (a blockUndo, serialized), so
+// the block can be taken back out of the UTXO set without a Reindex
+const undoBucket = "undo"
+
+// DisconnectBlock gives this back for blocks that went into the UTXO set
+// before undo records were kept, the only way back from those is a Reindex
+var ErrNoUndoData = errors.New("no undo data")
+
+// an output a block spent, and where it was in its transaction's
+// outputs so it can go back in the same place
+type spentOutput struct {
+	Txid   []byte
+	Index  int
+	Output tx.TXOutput
+}
+
+// everything ConnectBlock took out of the UTXO set for one block,
+// in the order it took them out
+type blockUndo struct {
+	Spent []spentOutput
+}
+
+func (u *blockUndo) Serialize() []byte {
+	var output bytes.Buffer
+	enc := gob.NewEncoder(&output)
+	if err := enc.Encode(u); err != nil {
+		log.Panic(err)
+	}
+	return output.Bytes()
+}
+
+func deserializeUndo(data []byte) (*blockUndo, error) {
+	var undo blockUndo
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&undo); err != nil {
+		return nil, fmt.Errorf("Decoding undo data: %w", err)
+	}
+	return &undo, nil
+}
+
+// takes a block back out of the UTXO set, the opposite of ConnectBlock.
+// The outputs its transactions made are removed and the ones they spent
+// are put back. Blocks have to be taken out newest first, starting at the tip
+func (utxos *UTXOSet) DisconnectBlock(dbtx *bolt.Tx, block *chain.Block) error {
+	b := dbtx.Bucket([]byte(UTXOSetbucket))
+	undos := dbtx.Bucket([]byte(undoBucket))
+	if b == nil || undos == nil || undos.Get(block.Hash) == nil {
+		return fmt.Errorf("%w for block %x", ErrNoUndoData, block.Hash)
+	}
+	undo, err := deserializeUndo(undos.Get(block.Hash))
+	if err != nil {
+		return err
+	}
+
+	// a later transaction in the block can spend an earlier one's
+	// outputs, so going backwards undoes everything in the right order
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		if err := b.Delete(block.Transactions[i].ID); err != nil {
+			return err
+		}
+	}
+	for i := len(undo.Spent) - 1; i >= 0; i-- {
+		spent := undo.Spent[i]
+		outputs := tx.TXOutputs{}
+		if data := b.Get(spent.Txid); data != nil {
+			outputs, err = tx.DeserializeOutputs(data)
+			if err != nil {
+				return err
+			}
+		}
+		if _, ok := outputs.Find(spent.Index); ok {
+			return fmt.Errorf("Undo data for block %x puts output %d of %x back, but it's unspent already", block.Hash, spent.Index, spent.Txid)
+		}
+		outputs.Add(spent.Index, spent.Output)
+		if err := b.Put(spent.Txid, outputs.Serialize()); err != nil {
+			return err
+		}
+	}
+
+	// the block could come back later (another reorganization),
+	// but then ConnectBlock makes it a new undo record anyway
+	return undos.Delete(block.Hash)
+}
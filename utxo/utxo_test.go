@@ -0,0 +1,198 @@
+package utxo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"blockchain/chain"
+	"blockchain/script"
+	"blockchain/tx"
+	"blockchain/wallet"
+)
+
+// an OutputFinder that always picks the one output it was given, so a
+// test decides which output of a transaction gets spent
+type pickOutput struct {
+	txid  []byte
+	index int
+	value int
+}
+
+func (p pickOutput) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int, error) {
+	return p.value, map[string][]int{hex.EncodeToString(p.txid): {p.index}}, nil
+}
+
+func TestSpendSecondOutputAfterFirst(t *testing.T) {
+	wallets, err := wallet.NewWallets(filepath.Join(t.TempDir(), wallet.FileName), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minerAddress, err := wallets.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverAddress, err := wallets.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner, receiver := wallets.Wallets[minerAddress], wallets.Wallets[receiverAddress]
+	bc, utxoset := newTestChain(t, miner)
+
+	// outputs 0 and 1 both go to receiver, the change is output 2
+	batch, err := tx.NewBatchTransaction(wallets, minerAddress, []tx.Payment{{To: receiverAddress, Amount: 3}, {To: receiverAddress, Amount: 4}}, 0, utxoset, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MineBlock(bc, []*tx.Transaction{batch}, minerAddress); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := tx.NewGeneralTransaction(receiver, minerAddress, 3, 0, tx.Locks{}, pickOutput{batch.ID, 0, 3}, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MineBlock(bc, []*tx.Transaction{first}, minerAddress); err != nil {
+		t.Fatal(err)
+	}
+
+	// output 1 has to still be output 1, not slide down to 0
+	if _, found, err := utxoset.FindOutput(batch.ID, 0); err != nil || found {
+		t.Fatalf("output 0 is still unspent (%v)", err)
+	}
+	output, found, err := utxoset.FindOutput(batch.ID, 1)
+	if err != nil || !found {
+		t.Fatalf("output 1 is gone (%v)", err)
+	}
+	if output.Value != 4 {
+		t.Fatalf("output 1 is worth %d, expected 4", output.Value)
+	}
+	coins, err := utxoset.SpendableCoins(wallet.HashPubKey(receiver.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coins) != 1 || coins[0].Index != 1 || coins[0].Value != 4 {
+		t.Fatalf("expected receiver to have output 1 worth 4, got %+v", coins)
+	}
+
+	// spending it the normal way signs and verifies against the right output
+	second, err := tx.NewGeneralTransaction(receiver, minerAddress, 4, 0, tx.Locks{}, utxoset, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MineBlock(bc, []*tx.Transaction{second}, minerAddress); err != nil {
+		t.Fatal(err)
+	}
+	if outputs, err := utxoset.FindUTXO(wallet.HashPubKey(receiver.PublicKey)); err != nil || len(outputs) != 0 {
+		t.Fatalf("receiver still has %d outputs (%v)", len(outputs), err)
+	}
+	checkUTXOSet(t, bc)
+	if err := bc.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpendableCoinsSkipsLockedOutputs(t *testing.T) {
+	miner, receiver := newTestWallet(t), newTestWallet(t)
+	minerAddress, receiverAddress := string(miner.GetAddress()), string(receiver.GetAddress())
+	bc, utxoset := newTestChain(t, miner)
+
+	// can go in a block two above the one it's mined in
+	locked, err := tx.NewGeneralTransaction(miner, receiverAddress, 5, 0, tx.Locks{RelativeLock: 2}, utxoset, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MineBlock(bc, []*tx.Transaction{locked}, minerAddress); err != nil {
+		t.Fatal(err)
+	}
+
+	pubKeyHash := wallet.HashPubKey(receiver.PublicKey)
+	coins, err := utxoset.SpendableCoins(pubKeyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coins) != 0 {
+		t.Fatalf("the locked output can't go in the next block, but got %+v", coins)
+	}
+	if _, err := tx.NewGeneralTransaction(receiver, minerAddress, 5, 0, tx.Locks{}, utxoset, bc); err == nil {
+		t.Fatal("built a transaction spending a locked output")
+	}
+
+	if _, err := MineBlock(bc, nil, minerAddress); err != nil {
+		t.Fatal(err)
+	}
+	coins, err = utxoset.SpendableCoins(pubKeyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coins) != 1 || coins[0].Value != 5 {
+		t.Fatalf("expected the output worth 5 to be spendable now, got %+v", coins)
+	}
+	spend, err := tx.NewGeneralTransaction(receiver, minerAddress, 5, 0, tx.Locks{}, utxoset, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MineBlock(bc, []*tx.Transaction{spend}, minerAddress); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutputsWithOtherScriptsArentOwned(t *testing.T) {
+	miner, victim := newTestWallet(t), newTestWallet(t)
+	bc, utxoset := newTestChain(t, miner)
+	pubKeyHash := wallet.HashPubKey(victim.PublicKey)
+
+	// a coinbase paying victim's key hash, except with a script
+	// that always fails instead of the standard one
+	coinbase, err := tx.NewCoinbaseTX(string(victim.GetAddress()), "", chain.BlockSubsidy(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase.Vout[0].Script = []byte{script.OP_0}
+	id := sha256.Sum256(coinbase.HashBytes())
+	coinbase.ID = id[:]
+	if _, err := bc.AddBlock([]*tx.Transaction{coinbase}, utxoset); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, err := utxoset.FindOutput(coinbase.ID, 0); err != nil || !found {
+		t.Fatalf("the output isn't in the UTXO set (%v)", err)
+	}
+	if coins, err := utxoset.SpendableCoins(pubKeyHash); err != nil || len(coins) != 0 {
+		t.Fatalf("expected no coins for victim, got %+v (%v)", coins, err)
+	}
+	if outputs, err := utxoset.FindUTXO(pubKeyHash); err != nil || len(outputs) != 0 {
+		t.Fatalf("expected no balance for victim, got %d outputs (%v)", len(outputs), err)
+	}
+}
+
+// two payments that wrap around to a negative amount would otherwise
+// need less than the miner has
+func TestBatchPaymentsCantOverflow(t *testing.T) {
+	wallets, err := wallet.NewWallets(filepath.Join(t.TempDir(), wallet.FileName), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minerAddress, err := wallets.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, utxoset := newTestChain(t, wallets.Wallets[minerAddress])
+
+	huge := math.MaxInt/2 + 1
+	payments := []tx.Payment{{To: minerAddress, Amount: huge}, {To: minerAddress, Amount: huge}}
+	if _, err := tx.NewBatchTransaction(wallets, minerAddress, payments, 0, utxoset, bc); !errors.Is(err, tx.ErrInvalidValue) {
+		t.Errorf("expected ErrInvalidValue, got %v", err)
+	}
+	payments = []tx.Payment{{To: minerAddress, Amount: math.MaxInt}}
+	if _, err := tx.NewBatchTransaction(wallets, minerAddress, payments, 1, utxoset, bc); !errors.Is(err, tx.ErrInvalidValue) {
+		t.Errorf("payment and fee: expected ErrInvalidValue, got %v", err)
+	}
+	payments = []tx.Payment{{To: minerAddress, Amount: 3}, {To: minerAddress, Amount: -2}}
+	if _, err := tx.NewBatchTransaction(wallets, minerAddress, payments, 0, utxoset, bc); err == nil {
+		t.Error("built a batch with a negative payment")
+	}
+}
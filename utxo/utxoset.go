@@ -0,0 +1,283 @@
+// the set of unspent transaction outputs, which is what a balance is
+// made of, and the mempool of transactions waiting to spend them
+package utxo
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"blockchain/chain"
+	"blockchain/tx"
+
+	"github.com/boltdb/bolt"
+)
+
+const UTXOSetbucket = "UTXOSet"
+
+// the idea behind the UTXOSet is to just avoid having to scan
+// the entire blockchain for transactions. It's like a cache of sorts
+// but it needs to be kept consistent with the real blockchain
+type UTXOSet struct {
+	Blockchain *chain.Blockchain
+}
+
+// this function kinda acts as a "refresh" for the UTXO set.
+// this will go through the entire blockchain via FindAllUnspentTXOs
+// so we want to call this sparingly, only when necessary
+func (utxos *UTXOSet) Reindex() error {
+	db := utxos.Blockchain.DB
+
+	UTXO, err := utxos.Blockchain.FindAllUnspentTXOs()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(dbtx *bolt.Tx) error {
+		// delete this bucket (erases all previously held data about the UTXO set)
+		err := dbtx.DeleteBucket([]byte(UTXOSetbucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := dbtx.CreateBucket([]byte(UTXOSetbucket))
+		if err != nil {
+			return err
+		}
+
+		// put each unspent TXoutput into Bolt
+		for txID, TXoutput := range UTXO {
+			key, err := hex.DecodeString(txID)
+			if err != nil {
+				return err
+			}
+			err = bucket.Put(key, TXoutput.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// every coin pubkeyHash can spend. Outputs that a transaction in the
+// mempool is already spending are left out, otherwise we'd build a double
+// spend. So are outputs whose relative lock keeps them from going in the
+// next block, the transaction would just sit in the mempool
+func (utxos *UTXOSet) SpendableCoins(pubkeyHash []byte) ([]tx.Coin, error) {
+	var coins []tx.Coin
+	// the relative lock of each coin, by position in coins
+	var relativeLocks []int
+	db := utxos.Blockchain.DB
+	mempool := Mempool{
+		Blockchain: utxos.Blockchain,
+	}
+	pendingSpent, err := mempool.SpentOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.View(func(dbtx *bolt.Tx) error {
+		b := dbtx.Bucket([]byte(UTXOSetbucket))
+		if b == nil {
+			return nil
+		}
+
+		// this is standard way to loop thru bucket
+		// look in Bolt docs
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			txID := hex.EncodeToString(k)
+			// first deserialize []byte to TXOutputs
+			outputs, err := tx.DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			// check if the output is unlockable via this pubkeyHash
+			for i, output := range outputs.Outputs {
+				idx := outputs.Index(i)
+				if output.IsLockedWithKey(pubkeyHash) && !pendingSpent[txID][idx] {
+					// k is only good until the transaction ends
+					txid := append([]byte{}, k...)
+					coins = append(coins, tx.Coin{Txid: txid, Index: idx, Value: output.Value})
+					relativeLocks = append(relativeLocks, output.RelativeLock)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// same rule as chain.CheckLocks, the output unlocks RelativeLock
+	// blocks after the one it's in. Looking that block up reads the
+	// chain, so it happens after the View above is done
+	next := utxos.Blockchain.GetBestHeight() + 1
+	spendable := coins[:0]
+	for i, coin := range coins {
+		if relativeLocks[i] > 0 {
+			_, block, err := utxos.Blockchain.FindTransactionBlock(coin.Txid)
+			if err != nil {
+				return nil, err
+			}
+			if next < block.Height+relativeLocks[i] {
+				continue
+			}
+		}
+		spendable = append(spendable, coin)
+	}
+	return spendable, nil
+}
+
+// gives you enough of an address's coins to pay amount (or all of them if
+// that's not enough), and how much they add up to. The coins are picked
+// in the order they're stored, see tx.CoinSelection for other ways
+func (utxos *UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int, error) {
+	selection := tx.CoinSelection{
+		Coins:  utxos,
+		Select: tx.SelectAccumulate,
+	}
+	return selection.FindSpendableOutputs(pubkeyHash, amount)
+}
+
+// just like FindSpendableOutputs except we don't return amount or map,
+// we just return a list of the actual TXOutput objects.
+func (utxos *UTXOSet) FindUTXO(pubKeyHash []byte) ([]tx.TXOutput, error) {
+	var UTXOs []tx.TXOutput
+	db := utxos.Blockchain.DB
+	err := db.View(func(dbtx *bolt.Tx) error {
+		b := dbtx.Bucket([]byte(UTXOSetbucket))
+		if b == nil {
+			return nil
+		}
+
+		// this is standard way to loop thru bucket
+		// look in Bolt docs
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			// first deserialize []byte to TXOutputs
+			outputs, err := tx.DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			// check if the output is unlockable via this pubkeyHash
+			for _, output := range outputs.Outputs {
+				if output.IsLockedWithKey(pubKeyHash) {
+					UTXOs = append(UTXOs, output)
+				}
+			}
+		}
+		return nil
+	})
+	return UTXOs, err
+}
+
+// looks up a single unspent output, returns false
+// if it isn't in the UTXO set (spent or never existed)
+func (utxos *UTXOSet) FindOutput(txid []byte, idx int) (tx.TXOutput, bool, error) {
+	var output tx.TXOutput
+	found := false
+
+	err := utxos.Blockchain.DB.View(func(dbtx *bolt.Tx) error {
+		b := dbtx.Bucket([]byte(UTXOSetbucket))
+		if b == nil {
+			return nil
+		}
+		data := b.Get(txid)
+		if data == nil {
+			return nil
+		}
+		outputs, err := tx.DeserializeOutputs(data)
+		if err != nil {
+			return err
+		}
+		output, found = outputs.Find(idx)
+		return nil
+	})
+	return output, found, err
+}
+
+// inform the UTXO Set about a new block that has appeared on the chain.
+// The chain calls this (see chain.ChainState) in the same Bolt transaction
+// that makes the block its tip, so the two can't disagree. Every input has
+// to spend an output that's in the set, which also catches two inputs in
+// the block spending the same one. What the block spends gets saved in the
+// undo bucket, so DisconnectBlock can take it back out
+func (utxos *UTXOSet) ConnectBlock(dbtx *bolt.Tx, block *chain.Block) error {
+	b, err := dbtx.CreateBucketIfNotExists([]byte(UTXOSetbucket))
+	if err != nil {
+		return err
+	}
+	undos, err := dbtx.CreateBucketIfNotExists([]byte(undoBucket))
+	if err != nil {
+		return err
+	}
+	undo := blockUndo{}
+
+	// loop over each transaction in this newly added block
+	for _, transaction := range block.Transactions {
+		// for each input, check which outputs it references. Removes
+		// those referenced outputs from the UTXO set, since they are no longer
+		// unspent. Coinbase transactions have no real inputs, but
+		// their outputs still need adding below
+		if !transaction.IsCoinbase() {
+			for _, vin := range transaction.Vin {
+				outputToRemoveIdx := vin.OutputIdx
+				curTxOutputs := b.Get(vin.Txid)
+				if curTxOutputs == nil {
+					return fmt.Errorf("%w: %x is not in the UTXO set", tx.ErrUnknownTransaction, vin.Txid)
+				}
+				txOutputs, err := tx.DeserializeOutputs(curTxOutputs)
+				if err != nil {
+					return err
+				}
+
+				// the rest keep their indexes, so a later input
+				// spending another output of this transaction still
+				// finds the right one
+				output, ok := txOutputs.Remove(outputToRemoveIdx)
+				if !ok {
+					return fmt.Errorf("%w: output %d of %x is not in the UTXO set", tx.ErrUnknownTransaction, outputToRemoveIdx, vin.Txid)
+				}
+				undo.Spent = append(undo.Spent, spentOutput{vin.Txid, outputToRemoveIdx, output})
+
+				// if there are no more outputs left for this transaction, don't
+				// bother updating the DB since there's nothing in the 'value'
+				// part of key/value
+				if len(txOutputs.Outputs) == 0 {
+					err = b.Delete(vin.Txid)
+				} else {
+					// delete old value and write new one into DB
+					err = b.Put(vin.Txid, txOutputs.Serialize())
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// ok, we've removed stale outputs. Now to add new outputs from
+		// this block! All outputs are guaranteed unspent since we just made
+		// the block before getting here. Outputs nobody can spend
+		// (anchored data) would just sit in the set forever, so skip them
+		newTxOutputs := tx.TXOutputs{}
+		for idx, output := range transaction.Vout {
+			if !output.IsUnspendable() {
+				newTxOutputs.Add(idx, output)
+			}
+		}
+		if len(newTxOutputs.Outputs) == 0 {
+			continue
+		}
+		// taking the block back out deletes the transaction's
+		// outputs, so an earlier one with the same ID would go too
+		if b.Get(transaction.ID) != nil {
+			return fmt.Errorf("Transaction %x is in the UTXO set already", transaction.ID)
+		}
+		if err := b.Put(transaction.ID, newTxOutputs.Serialize()); err != nil {
+			return err
+		}
+	}
+	return undos.Put(block.Hash, undo.Serialize())
+}
@@ -0,0 +1,169 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encrypted wallet files start with this, so we can tell them apart
+// from the plain gob files that older versions wrote
+var encryptedMagic = []byte("ENCWALLETS1\n")
+
+// the scrypt cost settings for new passphrases. They're stored in the
+// file, but open won't go above them, otherwise a file could ask for
+// gigabytes of memory before the passphrase is even checked
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32 // AES-256
+	saltLen      = 16
+)
+
+// what comes after encryptedMagic in the file. The ciphertext is the
+// gob encoded Wallets sealed with AES-GCM, using a key scrypt made
+// from the passphrase and Salt
+type encryptedFile struct {
+	Salt       []byte
+	N, R, P    int
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// asks whoever is using the wallets for the passphrase. It's only
+// called when the file turns out to be encrypted
+type PassphraseFunc func() ([]byte, error)
+
+// the key a passphrase opens, kept so SaveToFile can encrypt
+// again without asking for the passphrase a second time
+type walletKey struct {
+	salt    []byte
+	n, r, p int
+	key     []byte
+}
+
+func deriveKey(passphrase, salt []byte, n, r, p int) (*walletKey, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	return &walletKey{salt, n, r, p, key}, nil
+}
+
+// a fresh salt for a new passphrase, so the same passphrase
+// doesn't give the same key in two different files
+func newWalletKey(passphrase []byte) (*walletKey, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return deriveKey(passphrase, salt, scryptN, scryptR, scryptP)
+}
+
+func isEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, encryptedMagic)
+}
+
+func (k *walletKey) seal(plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	file := encryptedFile{
+		Salt:  k.salt,
+		N:     k.n,
+		R:     k.r,
+		P:     k.p,
+		Nonce: nonce,
+		// the magic goes in as additional data so it can't be swapped out
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, encryptedMagic),
+	}
+
+	output := bytes.NewBuffer(append([]byte{}, encryptedMagic...))
+	if err := gob.NewEncoder(output).Encode(file); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// the opposite of seal, gives back the plaintext and the key that
+// opened it. GCM checks the ciphertext wasn't changed, so a wrong
+// passphrase (or a damaged file) fails here instead of giving garbage
+func open(contents, passphrase []byte) ([]byte, *walletKey, error) {
+	var file encryptedFile
+	dec := gob.NewDecoder(bytes.NewReader(contents[len(encryptedMagic):]))
+	if err := dec.Decode(&file); err != nil {
+		return nil, nil, err
+	}
+	if err := checkCost(file.N, file.R, file.P); err != nil {
+		return nil, nil, err
+	}
+
+	k, err := deriveKey(passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, nil, errors.New("bad nonce length")
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, encryptedMagic)
+	if err != nil {
+		return nil, nil, ErrWrongPassphrase
+	}
+	return plaintext, k, nil
+}
+
+// the cost settings a file asks for have to be ones we could have
+// written, scrypt only takes an N that's a power of two anyway
+func checkCost(n, r, p int) error {
+	if n < 2 || n > scryptN || n&(n-1) != 0 {
+		return fmt.Errorf("scrypt N is %d, it has to be a power of two up to %d", n, scryptN)
+	}
+	if r < 1 || r > scryptR || p < 1 || p > scryptP {
+		return fmt.Errorf("scrypt r and p are %d and %d, they can't be above %d and %d", r, p, scryptR, scryptP)
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// whether SaveToFile encrypts these wallets
+func (w *Wallets) Encrypted() bool {
+	return w.key != nil
+}
+
+// encrypts the wallets with a new passphrase from now on, whether they
+// were encrypted before or not. Nothing changes on disk until SaveToFile
+func (w *Wallets) SetPassphrase(passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return fmt.Errorf("the passphrase can't be empty")
+	}
+	k, err := newWalletKey(passphrase)
+	if err != nil {
+		return err
+	}
+	w.key = k
+	return nil
+}
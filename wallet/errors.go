@@ -0,0 +1,13 @@
+package wallet
+
+import "errors"
+
+// check for these with errors.Is, they usually come wrapped with the address
+var (
+	// the wallets file doesn't have the keys for an address
+	ErrWalletNotFound = errors.New("wallet not found")
+	// an address that doesn't decode or has a bad checksum
+	ErrInvalidAddress = errors.New("invalid address")
+	// the wallets file is encrypted and the passphrase doesn't open it
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
@@ -0,0 +1,192 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// every address comes from one mnemonic phrase, so writing the phrase
+// down once is the whole backup. The keys are derived like BIP32, but
+// Bitcoin's BIP32 only works on secp256k1 and our keys are P-256, so
+// we follow SLIP-0010 which is the same idea for other curves.
+// SLIP-0010 on P-256 only has hardened derivation, which is all we need
+// since we never derive public keys without the private ones
+
+// the key SLIP-0010 uses to turn a P-256 seed into the master key
+const masterKeySecret = "Nist256p1 seed"
+
+// 128 bits of entropy is a 12 word phrase
+const mnemonicEntropyBits = 128
+
+// restorewallet stops looking after this many addresses in a row
+// that have never received anything
+const gapLimit = 20
+
+// indexes from here up are hardened
+const hardened uint32 = 1 << 31
+
+// m/44'/1'/0'/0', the address index comes after it. 1 is the coin
+// type every test network uses, we don't have one of our own
+var accountPath = []uint32{44, 1, 0, 0}
+
+// a private key and its chain code, the chain code is what lets the
+// key have children without giving away the key itself
+type extendedKey struct {
+	key       *big.Int
+	chainCode []byte
+}
+
+// a new random mnemonic phrase for a brand new wallets file
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+func masterKey(seed []byte) *extendedKey {
+	n := elliptic.P256().Params().N
+	mac := hmac.New(sha512.New, []byte(masterKeySecret))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+	for {
+		key := new(big.Int).SetBytes(I[:32])
+		if key.Sign() != 0 && key.Cmp(n) < 0 {
+			return &extendedKey{key, I[32:]}
+		}
+		// the chance of this is about 1 in 2^32, SLIP-0010 says to
+		// just hash again
+		mac = hmac.New(sha512.New, []byte(masterKeySecret))
+		mac.Write(I)
+		I = mac.Sum(nil)
+	}
+}
+
+// the hardened child at index (without the hardened bit)
+func (k *extendedKey) child(index uint32) *extendedKey {
+	n := elliptic.P256().Params().N
+	var indexBytes [4]byte
+	binary.BigEndian.PutUint32(indexBytes[:], index|hardened)
+
+	// 0x00 || parent key || index
+	data := append([]byte{0x00}, k.key.FillBytes(make([]byte, 32))...)
+	data = append(data, indexBytes[:]...)
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		I := mac.Sum(nil)
+
+		childKey := new(big.Int).SetBytes(I[:32])
+		if childKey.Cmp(n) < 0 {
+			childKey.Add(childKey, k.key)
+			childKey.Mod(childKey, n)
+			if childKey.Sign() != 0 {
+				return &extendedKey{childKey, I[32:]}
+			}
+		}
+		// out of range, SLIP-0010 says to try 0x01 || IR || index
+		data = append([]byte{0x01}, I[32:]...)
+		data = append(data, indexBytes[:]...)
+	}
+}
+
+// the wallet at m/44'/1'/0'/0'/index' for a mnemonic
+func deriveWallet(mnemonic string, index uint32) (*Wallet, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	k := masterKey(seed)
+	for _, i := range accountPath {
+		k = k.child(i)
+	}
+	k = k.child(index)
+
+	curve := elliptic.P256()
+	priv := new(ecdsa.PrivateKey)
+	priv.Curve = curve
+	priv.D = k.key
+	priv.X, priv.Y = curve.ScalarBaseMult(k.key.Bytes())
+	return walletFromKey(priv), nil
+}
+
+// makes mnemonic the phrase these wallets come from. A file can only
+// ever have one, changing it would leave the old addresses unrecoverable
+func (w *Wallets) setMnemonic(mnemonic string) error {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return errors.New("the mnemonic isn't valid, check the words and their order")
+	}
+	if w.Mnemonic != "" && w.Mnemonic != mnemonic {
+		return errors.New("these wallets already come from a different mnemonic")
+	}
+	w.Mnemonic = mnemonic
+	return nil
+}
+
+// adds back the addresses of mnemonic. used says if an address has ever
+// received coins, and we keep deriving until gapLimit addresses in a row
+// haven't. Gives back the restored addresses in order
+func (w *Wallets) Restore(mnemonic string, used func(address string) (bool, error)) ([]string, error) {
+	if err := w.setMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	var found []*Wallet
+	lastUsed := -1
+	for index := uint32(0); int(index) <= lastUsed+gapLimit; index++ {
+		wallet, err := deriveWallet(w.Mnemonic, index)
+		if err != nil {
+			return nil, err
+		}
+		isUsed, err := used(string(wallet.GetAddress()))
+		if err != nil {
+			return nil, err
+		}
+		if isUsed {
+			lastUsed = int(index)
+		}
+		found = append(found, wallet)
+	}
+
+	// keep everything up to the last used one, and at least the first
+	// address so there's somewhere to receive coins
+	keep := lastUsed + 1
+	if keep < 1 {
+		keep = 1
+	}
+	var addresses []string
+	for _, wallet := range found[:keep] {
+		address := string(wallet.GetAddress())
+		w.Wallets[address] = wallet
+		addresses = append(addresses, address)
+	}
+	if uint32(keep) > w.NextIndex {
+		w.NextIndex = uint32(keep)
+	}
+	return addresses, nil
+}
+
+// the next address of the mnemonic. The wallets need one first,
+// see CreateWallet
+func (w *Wallets) nextWallet() (*Wallet, error) {
+	if w.Mnemonic == "" {
+		return nil, fmt.Errorf("no mnemonic to derive keys from")
+	}
+	wallet, err := deriveWallet(w.Mnemonic, w.NextIndex)
+	if err != nil {
+		return nil, err
+	}
+	w.NextIndex++
+	return wallet, nil
+}
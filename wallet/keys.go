@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// public keys are SEC1 compressed: one byte saying if Y is even or odd
+// and then X, always padded to 32 bytes. Wallets made before this used
+// X.Bytes()||Y.Bytes(), which drops leading zero bytes, so a key could
+// come out shorter than 64 bytes and get split in the wrong place.
+// Those old keys are still accepted, see parseLegacyPubKey
+const CompressedPubKeyLen = 33
+const legacyPubKeyLen = 64
+
+// the size of one coordinate or scalar on P-256
+const coordinateLen = 32
+
+// the bytes that go on transaction inputs for a public key
+func MarshalPubKey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(pub.Curve, pub.X, pub.Y)
+}
+
+// the opposite of MarshalPubKey. Anything that isn't a compressed key or
+// an old style key, or isn't a point on the curve, is an error
+func ParsePubKey(b []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+
+	switch {
+	case len(b) == CompressedPubKeyLen:
+		x, y = elliptic.UnmarshalCompressed(curve, b)
+		if x == nil {
+			return nil, errors.New("public key is not a compressed point on P-256")
+		}
+	case len(b) > CompressedPubKeyLen && len(b) <= legacyPubKeyLen:
+		var err error
+		x, y, err = parseLegacyPubKey(curve, b)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("public key is %d bytes, it has to be %d (or up to %d for old keys)", len(b), CompressedPubKeyLen, legacyPubKeyLen)
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// an old style key is X and Y without their leading zero bytes, so when
+// it's short there's no telling from the key alone where X ends. Every
+// split that leaves both at most 32 bytes (which pads them both back to
+// 32) gets tried, and exactly one of them has to be a point on the curve
+func parseLegacyPubKey(curve elliptic.Curve, b []byte) (*big.Int, *big.Int, error) {
+	var x, y *big.Int
+	found := 0
+	for xLen := len(b) - coordinateLen; xLen <= coordinateLen; xLen++ {
+		splitX := new(big.Int).SetBytes(b[:xLen])
+		splitY := new(big.Int).SetBytes(b[xLen:])
+		if curve.IsOnCurve(splitX, splitY) {
+			x, y = splitX, splitY
+			found++
+		}
+	}
+
+	switch found {
+	case 0:
+		return nil, nil, fmt.Errorf("old style public key %x is not a point on P-256", b)
+	case 1:
+		return x, y, nil
+	default:
+		return nil, nil, fmt.Errorf("old style public key %x splits into %d different points on P-256, there's no telling which one it is", b, found)
+	}
+}
+
+// whether the wallet still has an old style public key, see migratewallet
+func (w *Wallet) IsLegacy() bool {
+	return len(w.PublicKey) != CompressedPubKeyLen
+}
+
+// the same private key with a compressed public key. Addresses are the hash
+// of the public key bytes, so this is a different address than w
+func (w *Wallet) Upgraded() *Wallet {
+	priv := w.PrivateKey
+	return walletFromKey(&priv)
+}
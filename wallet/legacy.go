@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/gob"
+	"fmt"
+	"math/big"
+)
+
+// the very first wallets files were gob encoded straight from the structs,
+// ecdsa.PrivateKey and all. Wallet has its own GobDecode now, so those
+// files don't decode as Wallets anymore. These types have the same field
+// names as the old ones, which is all gob looks at. The curve was in an
+// interface field, and since it's always P-256 we leave it out and gob
+// skips it
+type legacyWallets struct {
+	Wallets map[string]*legacyWallet
+}
+
+type legacyWallet struct {
+	PrivateKey legacyPrivateKey
+	PublicKey  []byte
+}
+
+type legacyPrivateKey struct {
+	PublicKey legacyPublicKey
+	D         *big.Int
+}
+
+type legacyPublicKey struct {
+	X, Y *big.Int
+}
+
+// decodes a wallets file from before Wallet had GobDecode. The keys keep
+// their old public keys (and so their addresses), migratewallet
+// moves their coins over to compressed ones
+func decodeLegacyWallets(data []byte) (*Wallets, error) {
+	var old legacyWallets
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&old); err != nil {
+		return nil, err
+	}
+
+	wallets := Wallets{
+		Wallets: make(map[string]*Wallet),
+	}
+	curve := elliptic.P256()
+	for address, lw := range old.Wallets {
+		if lw.PrivateKey.D == nil {
+			return nil, fmt.Errorf("the key for %s has no private key", address)
+		}
+		w := &Wallet{PublicKey: lw.PublicKey}
+		w.PrivateKey.Curve = curve
+		w.PrivateKey.D = lw.PrivateKey.D
+		w.PrivateKey.X, w.PrivateKey.Y = curve.ScalarBaseMult(lw.PrivateKey.D.Bytes())
+		wallets.Wallets[address] = w
+	}
+	return &wallets, nil
+}
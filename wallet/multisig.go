@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"blockchain/utils"
+)
+
+// the most keys a multisig can have, same as Bitcoin's CHECKMULTISIG
+const MaxMultisigKeys = 15
+
+// M of the N public keys have to sign to spend coins sent to a multisig
+// address. Like Bitcoin's P2SH, the address and the outputs only have
+// the hash of this, and whoever spends the coins shows the whole thing
+// (on the input's PublicKey) so it can be checked against the hash
+type MultisigScript struct {
+	M       int
+	PubKeys [][]byte
+}
+
+func NewMultisigScript(m int, pubKeys [][]byte) (*MultisigScript, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxMultisigKeys {
+		return nil, fmt.Errorf("a multisig needs between 1 and %d keys, not %d", MaxMultisigKeys, len(pubKeys))
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("m has to be between 1 and the number of keys (%d), not %d", len(pubKeys), m)
+	}
+	for i, pubKey := range pubKeys {
+		if _, err := ParsePubKey(pubKey); err != nil {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+		for _, other := range pubKeys[:i] {
+			if bytes.Equal(pubKey, other) {
+				return nil, fmt.Errorf("key %d is in the multisig twice", i+1)
+			}
+		}
+	}
+	return &MultisigScript{m, pubKeys}, nil
+}
+
+// M, N and then the keys, each with its length in front. Written out
+// by hand instead of with gob since the hash has to be the same everywhere
+func (ms *MultisigScript) Serialize() []byte {
+	var buff bytes.Buffer
+	buff.WriteByte(byte(ms.M))
+	buff.WriteByte(byte(len(ms.PubKeys)))
+	for _, pubKey := range ms.PubKeys {
+		utils.WriteBytes(&buff, pubKey)
+	}
+	return buff.Bytes()
+}
+
+// the opposite of Serialize. Anything NewMultisigScript wouldn't
+// accept, or with bytes left over at the end, is an error
+func DeserializeMultisigScript(data []byte) (*MultisigScript, error) {
+	if len(data) < 2 {
+		return nil, errors.New("multisig script is too short")
+	}
+	m, n := int(data[0]), int(data[1])
+	rest := data[2:]
+
+	var pubKeys [][]byte
+	for i := 0; i < n; i++ {
+		pubKey, remaining, err := utils.ReadBytes(rest)
+		if err != nil {
+			return nil, fmt.Errorf("multisig script key %d: %w", i+1, err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+		rest = remaining
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("multisig script has extra bytes at the end")
+	}
+	return NewMultisigScript(m, pubKeys)
+}
+
+// what outputs sent to the multisig are locked with
+func (ms *MultisigScript) Hash() []byte {
+	return HashPubKey(ms.Serialize())
+}
+
+func (ms *MultisigScript) Address() string {
+	return addressFromHash(MultisigVersion, ms.Hash())
+}
+
+// the address a multisig output's hash goes with
+func MultisigAddressFromHash(scriptHash []byte) string {
+	return addressFromHash(MultisigVersion, scriptHash)
+}
+
+// remembers a multisig so its coins can be spent from these wallets,
+// gives back its address
+func (w *Wallets) AddMultisig(ms *MultisigScript) string {
+	if w.Multisig == nil {
+		w.Multisig = make(map[string][]byte)
+	}
+	address := ms.Address()
+	w.Multisig[address] = ms.Serialize()
+	return address
+}
+
+func (w *Wallets) FindMultisig(address string) (*MultisigScript, error) {
+	script, ok := w.Multisig[address]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, address)
+	}
+	return DeserializeMultisigScript(script)
+}
+
+// the wallets that have keys of ms, in the order the keys are in ms.
+// Those are the ones that can sign for it
+func (w *Wallets) Signers(ms *MultisigScript) []*Wallet {
+	var signers []*Wallet
+	for _, pubKey := range ms.PubKeys {
+		for _, wallet := range w.Wallets {
+			if bytes.Equal(wallet.PublicKey, pubKey) {
+				signers = append(signers, wallet)
+				break
+			}
+		}
+	}
+	return signers
+}
@@ -0,0 +1,326 @@
+// wallets are key pairs, and addresses are what people
+// use to send coins to the owner of a key pair
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// the first byte of an address says what kind it is. Like Bitcoin,
+// 0x00 is one public key and 0x05 is a multisig (see multisig.go)
+const version = byte(0x00)
+const MultisigVersion = byte(0x05)
+
+// where the wallets are kept, like the database each node
+// gets its own file when it has an ID (see FilePath)
+const FileName = "wallets.dat"
+const nodeFileName = "wallets_%s.dat"
+const addressChecksumLen = 4 // use 4 bytes of checksum in addresses
+
+// a wallet is a public key and a private key
+type Wallet struct {
+	PrivateKey ecdsa.PrivateKey
+	PublicKey  []byte
+}
+
+// Wallets is a map from string (the address) to Wallet object
+type Wallets struct {
+	Wallets map[string]*Wallet
+	// the phrase the keys are derived from (see hd.go), and the index of
+	// the next address to derive. Files made before this existed don't
+	// have a mnemonic, their keys are random and stay that way
+	Mnemonic  string
+	NextIndex uint32
+	// multisig address -> its serialized MultisigScript, needed to spend
+	// from it. Whoever has enough of the keys in here can sign
+	Multisig map[string][]byte
+	// the file these were loaded from and SaveToFile writes to.
+	// Unexported, so gob leaves it out of the file itself
+	path string
+	// nil unless the file is encrypted (see encrypt.go)
+	key *walletKey
+}
+
+// ecdsa.PrivateKey holds its curve as an interface, which newer versions
+// of gob refuse to encode. So on disk we only keep the private number D
+// and the public key, and rebuild the rest of the key when decoding
+type walletData struct {
+	D         []byte
+	PublicKey []byte
+}
+
+func (w Wallet) GobEncode() ([]byte, error) {
+	var output bytes.Buffer
+	enc := gob.NewEncoder(&output)
+	err := enc.Encode(walletData{w.PrivateKey.D.Bytes(), w.PublicKey})
+	return output.Bytes(), err
+}
+
+func (w *Wallet) GobDecode(data []byte) error {
+	var wd walletData
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&wd); err != nil {
+		return err
+	}
+
+	curve := elliptic.P256()
+	w.PrivateKey.Curve = curve
+	w.PrivateKey.D = new(big.Int).SetBytes(wd.D)
+	w.PrivateKey.X, w.PrivateKey.Y = curve.ScalarBaseMult(wd.D)
+	w.PublicKey = wd.PublicKey
+	return nil
+}
+
+func NewWallet() (*Wallet, error) {
+	// make an elliptic curve
+	curve := elliptic.P256()
+
+	// generate public/private key
+	// I know it just says privatekey but both are inside the struct
+	privatekey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return walletFromKey(privatekey), nil
+}
+
+func walletFromKey(privatekey *ecdsa.PrivateKey) *Wallet {
+	// One thing to notice: in elliptic curve based algorithms, public keys
+	// are points on a curve. Thus, a public key is
+	// a combination of X, Y coordinates.
+	// Like Bitcoin we only keep X and whether Y is odd, Y can be worked
+	// out from those (see keys.go)
+	return &Wallet{
+		PrivateKey: *privatekey,
+		PublicKey:  MarshalPubKey(&privatekey.PublicKey),
+	}
+}
+
+// where the wallets of the node with this ID live inside dataDir.
+// An empty dataDir means the working directory
+func FilePath(dataDir, nodeID string) string {
+	if nodeID == "" {
+		return filepath.Join(dataDir, FileName)
+	}
+	return filepath.Join(dataDir, fmt.Sprintf(nodeFileName, nodeID))
+}
+
+// loads up a wallets object with all the wallets made so far from path
+// looks for a file, if it doesnt exist, it just returns empty array of *Wallet.
+// passphrase is only asked for if the file is encrypted, and can be nil
+// when there's nobody to ask
+func NewWallets(path string, passphrase PassphraseFunc) (*Wallets, error) {
+	w := Wallets{
+		Wallets: make(map[string]*Wallet),
+		path:    path,
+	}
+
+	// check if file exists first, if it doesn't then just
+	// load an empty one and return it
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &w, nil
+	}
+
+	// otherwise we have wallets already (in the file). Read the info in
+	fileContents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *walletKey
+	if isEncrypted(fileContents) {
+		if passphrase == nil {
+			return nil, fmt.Errorf("%w: %s is encrypted and no passphrase was given", ErrWrongPassphrase, path)
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		fileContents, key, err = open(fileContents, pass)
+		if err != nil {
+			return nil, fmt.Errorf("Opening %s: %w", path, err)
+		}
+	}
+
+	// decode the values in the file using gob
+	var wallets Wallets
+
+	// we need to make a new bytes.Reader here, since NewDecoder expects this
+	dec := gob.NewDecoder(bytes.NewReader(fileContents))
+	err = dec.Decode(&wallets)
+	if err != nil {
+		// files that old were never encrypted
+		legacy, legacyErr := decodeLegacyWallets(fileContents)
+		if key != nil || legacyErr != nil {
+			return nil, fmt.Errorf("Decoding %s: %w", path, err)
+		}
+		wallets = *legacy
+	}
+	wallets.path = path
+	wallets.key = key
+	return &wallets, nil
+
+}
+
+// HashPubKey hashes public key
+func HashPubKey(pubKey []byte) []byte {
+	sha := sha256.Sum256(pubKey)
+
+	// ripemd160
+	riphasher := ripemd160.New()
+	riphasher.Write(sha[:])
+	publicKeyHash := riphasher.Sum(nil)
+	return publicKeyHash
+}
+
+// just base 58 DECODES, then removes checksum and version to
+// leave us with the public key hash part
+func GetPubkeyhashFromAddr(address string) ([]byte, error) {
+	if !ValidateAddress(address) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+	}
+	decoded := base58.Decode(address)
+	return decoded[1 : len(decoded)-addressChecksumLen], nil
+}
+
+// like GetPubkeyhashFromAddr, but also gives back the version byte
+// so multisig addresses can be told apart from normal ones
+func DecodeAddress(address string) (byte, []byte, error) {
+	hash, err := GetPubkeyhashFromAddr(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	return base58.Decode(address)[0], hash, nil
+}
+
+// generates a bitcoin address using a Wallet's public key
+// it goes 1 byte version | public key hash | 4 byte checksum
+func (w *Wallet) GetAddress() []byte {
+	return []byte(AddressFromPubKeyHash(HashPubKey(w.PublicKey)))
+}
+
+// the opposite of GetPubkeyhashFromAddr, turns the hash an output
+// is locked with back into the address people know it by
+func AddressFromPubKeyHash(publicKeyHash []byte) string {
+	return addressFromHash(version, publicKeyHash)
+}
+
+func addressFromHash(version byte, publicKeyHash []byte) string {
+	versionAndHash := append([]byte{version}, publicKeyHash...)
+
+	checksum := checksum(versionAndHash)
+
+	// add version to the beginning
+	output := versionAndHash
+	output = append(output, checksum...)
+
+	return base58.Encode(output)
+}
+
+// Checksum generates a checksum for a public key
+func checksum(payload []byte) []byte {
+	firstSHA := sha256.Sum256(payload)
+	secondSHA := sha256.Sum256(firstSHA[:])
+
+	return secondSHA[:addressChecksumLen]
+}
+
+// derives the next wallet from the mnemonic and adds it to the wallets
+// object, gives back its address. If there's no mnemonic yet (a new
+// file, or one from before mnemonics) a new one is made first
+func (w *Wallets) CreateWallet() (string, error) {
+	if w.Mnemonic == "" {
+		mnemonic, err := NewMnemonic()
+		if err != nil {
+			return "", err
+		}
+		w.Mnemonic = mnemonic
+	}
+	wallet, err := w.nextWallet()
+	if err != nil {
+		return "", err
+	}
+	address := string(wallet.GetAddress())
+	w.Wallets[address] = wallet
+
+	return address, nil
+}
+
+// find a wallet (private/pub key pair) given a human readable address.
+// The map is keyed by address so this is just a lookup
+func (w *Wallets) FindWallet(address string) (Wallet, error) {
+	wallet, ok := w.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("%w: %s", ErrWalletNotFound, address)
+	}
+	return *wallet, nil
+}
+
+// this serializes the Wallets object and writes to the file it came
+// from, encrypted if they have a passphrase. Only the owner can read it
+func (w *Wallets) SaveToFile() error {
+	var output bytes.Buffer
+	enc := gob.NewEncoder(&output)
+	err := enc.Encode(w)
+	if err != nil {
+		return err
+	}
+	contents := output.Bytes()
+	if w.key != nil {
+		contents, err = w.key.seal(contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(w.path), 0700)
+	if err != nil {
+		return err
+	}
+	// write a new file and move it over the old one. That way an old 0644
+	// file gets replaced instead of keeping its mode, and the wallets
+	// aren't lost if we crash halfway through writing
+	file, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(contents)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), w.path)
+}
+
+// to validate, we will use the checksum. Strip away the checksum value
+// calculate the sha256 hash twice on version+hash, should be equal to old checksum
+func ValidateAddress(addr string) bool {
+	byteaddr := base58.Decode(addr)
+	// needs at least the version byte and the checksum
+	if len(byteaddr) <= 1+addressChecksumLen {
+		return false
+	}
+	if byteaddr[0] != version && byteaddr[0] != MultisigVersion {
+		return false
+	}
+	versionAndHash := byteaddr[:len(byteaddr)-addressChecksumLen]
+	actualChecksum := byteaddr[len(byteaddr)-addressChecksumLen:]
+	checksum := checksum(versionAndHash)
+	return bytes.Equal(checksum, actualChecksum)
+}
@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the wallets.dat checked in at the top of the repo was written by the
+// first version of the program, before Wallet had its own GobDecode
+const legacyWalletsFile = "../wallets.dat"
+
+func TestNewWalletsReadsLegacyFile(t *testing.T) {
+	wallets, err := NewWallets(legacyWalletsFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wallets.Wallets) == 0 {
+		t.Fatal("no wallets in", legacyWalletsFile)
+	}
+
+	for address, w := range wallets.Wallets {
+		if !w.IsLegacy() {
+			t.Errorf("%s: expected an old style public key, it's %d bytes", address, len(w.PublicKey))
+		}
+		if got := string(w.GetAddress()); got != address {
+			t.Errorf("%s: public key gives address %s", address, got)
+		}
+		// the private key has to be the one that goes with the public key
+		pub := append(w.PrivateKey.X.Bytes(), w.PrivateKey.Y.Bytes()...)
+		if !bytes.Equal(pub, w.PublicKey) {
+			t.Errorf("%s: private key doesn't match the public key", address)
+		}
+	}
+}
+
+func TestLegacyWalletsSaveInNewFormat(t *testing.T) {
+	data, err := os.ReadFile(legacyWalletsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wallets, err := NewWallets(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeLegacyWallets(data); err == nil {
+		t.Fatal("saved file is still in the old format")
+	}
+
+	saved, err := NewWallets(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Wallets) != len(wallets.Wallets) {
+		t.Fatalf("saved %d wallets, read back %d", len(wallets.Wallets), len(saved.Wallets))
+	}
+	for address, w := range wallets.Wallets {
+		got, err := saved.FindWallet(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 || !bytes.Equal(got.PublicKey, w.PublicKey) {
+			t.Errorf("%s: keys changed when saved", address)
+		}
+	}
+}
+
+// old keys dropped the leading zero bytes of X and Y, so about one
+// in 128 of them is shorter than 64 bytes
+func TestParseShortLegacyPubKey(t *testing.T) {
+	for _, short := range []string{"X", "Y"} {
+		var key *ecdsa.PrivateKey
+		for key == nil || len(pickCoordinate(key, short).Bytes()) == coordinateLen {
+			var err error
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		legacy := append(key.X.Bytes(), key.Y.Bytes()...)
+
+		pub, err := ParsePubKey(legacy)
+		if err != nil {
+			t.Fatalf("short %s, %d byte key: %v", short, len(legacy), err)
+		}
+		if pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+			t.Errorf("short %s, %d byte key parsed to the wrong point", short, len(legacy))
+		}
+	}
+
+	// not on the curve however it's split
+	garbage := bytes.Repeat([]byte{0xab}, legacyPubKeyLen-1)
+	if _, err := ParsePubKey(garbage); err == nil {
+		t.Error("parsed a key that isn't a point")
+	}
+}
+
+func pickCoordinate(key *ecdsa.PrivateKey, name string) *big.Int {
+	if name == "X" {
+		return key.X
+	}
+	return key.Y
+}
+
+// a file asking for more work than we'd ever write gets turned down
+// before scrypt runs, instead of eating all the memory
+func TestOpenRejectsScryptCost(t *testing.T) {
+	for _, cost := range [][3]int{{scryptN << 1, scryptR, scryptP}, {3 << 10, scryptR, scryptP}, {0, scryptR, scryptP}, {scryptN, 1 << 20, scryptP}, {scryptN, scryptR, 1 << 20}} {
+		k := &walletKey{salt: make([]byte, saltLen), n: cost[0], r: cost[1], p: cost[2], key: make([]byte, scryptKeyLen)}
+		sealed, err := k.seal([]byte("wallets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := open(sealed, []byte("passphrase")); err == nil || errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("N=%d r=%d p=%d: expected the cost to be rejected, got %v", cost[0], cost[1], cost[2], err)
+		}
+	}
+}